@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 
@@ -12,6 +14,8 @@ import (
 
 var (
 	aliasDescription string
+	aliasData        string
+	aliasFromCurl    string
 	aliasParams      map[string]map[string]*string
 )
 
@@ -29,10 +33,14 @@ func addAliases(service string) {
 	action.Flag("description", "a short description of the alias, will be used in generated help documentation").
 		StringVar(&aliasDescription)
 
-	action.Arg("data", "data to be sent with the request").
+	action.Arg("data", "data to be sent with the request, use @file to store a reference to a file").
 		StringVar(&request.Data)
 
+	action.Flag("from-curl", "make the alias from a curl command, values matching parameters become :param placeholders").
+		StringVar(&aliasFromCurl)
+
 	settings.Flags(action, false)
+	settings.FormFlags(action, false)
 
 	aliasParams = make(map[string]map[string]*string)
 
@@ -85,11 +93,21 @@ func setAliases(current string) error {
 			// attached data arguments to post and put methods
 			method := string(b.Get([]byte("method")))
 			if method == "post" || method == "put" {
-				a.Arg("data", "data to send in the request").
-					StringVar(&request.Data)
+				a.Arg("data", dataUsage+", defaults to the stored data").
+					StringVar(&aliasData)
+				a.Flag("edit", "edit the request data in $EDITOR before sending it, starting from the stored data").
+					BoolVar(&request.Edit)
+			}
+
+			// websocket aliases can replace the stored subscribe message
+			if method == "ws" {
+				a.Arg("message", "message to send once connected, defaults to the stored message").
+					StringVar(&aliasData)
+				wsFlags(a)
 			}
 
 			requestFlags(a, true)
+			settings.FormFlags(a, true)
 
 			aliasParams[string(k)] = make(map[string]*string)
 
@@ -125,10 +143,27 @@ func setAliases(current string) error {
 				}
 			}
 
-			data := string(b.Get([]byte("data")))
-			for p, _ := range findParams(data) {
+			// turn form parameters into flags
+			for _, key := range []string{"form", "files"} {
+				if f := b.Bucket([]byte(key)); f != nil {
+					if err := f.ForEach(func(_, value []byte) error {
+						addAliasParamsFromString(a, string(k), string(value))
+						return nil
+					}); err != nil {
+						return err
+					}
+				}
+			}
+
+			// parameters in a data file were found when the alias was saved
+			for p, _ := range findParams(string(b.Get([]byte("data")))) {
 				addAliasParam(a, string(k), p)
 			}
+			if params := b.Get([]byte("data-parameters")); len(params) > 0 {
+				for _, p := range strings.Split(string(params), ",") {
+					addAliasParam(a, string(k), p)
+				}
+			}
 
 			return nil
 		})
@@ -136,6 +171,11 @@ func setAliases(current string) error {
 }
 
 func addAliasParam(cmd *kingpin.CmdClause, name, param string) {
+	// the same parameter can be used in the path, headers, queries, and data
+	if _, ok := aliasParams[name][param]; ok {
+		return
+	}
+
 	desc := fmt.Sprintf("set :%s parameter", param)
 	aliasParams[name][param] = cmd.Flag(param, desc).String()
 }
@@ -147,6 +187,12 @@ func addAlias() error {
 			return err
 		}
 
+		if aliasFromCurl != "" {
+			if err := fromCurl(aliasFromCurl, LoadSettings(sb)); err != nil {
+				return err
+			}
+		}
+
 		ab, err := sb.CreateBucketIfNotExists([]byte("aliases"))
 		if err != nil {
 			return err
@@ -183,7 +229,7 @@ func addAlias() error {
 		}
 
 		if request.Data != "" {
-			if err := a.Put([]byte("data"), []byte(request.Data)); err != nil {
+			if err := storeAliasData(a, request.Data); err != nil {
 				return err
 			}
 		}
@@ -197,9 +243,55 @@ func addAlias() error {
 }
 
 func Perform(name string) {
-	request.Alias = name
-	err := db.View(func(tx *bolt.Tx) error {
-		sb, err := request.ServiceBucket(tx)
+	// get parameters from alias specific flags
+	params := make(map[string]string)
+	for param := range aliasParams[name] {
+		if *aliasParams[name][param] != "" {
+			params[param] = *aliasParams[name][param]
+		}
+	}
+
+	r, err := aliasRequest(name, params)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	// data provided when calling the alias replaces the stored data
+	if aliasData != "" {
+		r.Data = aliasData
+	}
+
+	if r.Method == "ws" {
+		Session(r)
+		return
+	}
+
+	run(r)
+}
+
+// aliasRequest builds the request stored in the alias, the parameters
+// override both the stored and command line parameters
+func aliasRequest(name string, params map[string]string) (*Request, error) {
+	r := newRequest()
+	if err := r.loadAlias(name); err != nil {
+		return nil, err
+	}
+
+	for param, value := range params {
+		r.Flags.Parameters[param] = value
+	}
+
+	return r, nil
+}
+
+// loadAlias sets the method, path, and data stored in the alias for the
+// requests service
+func (r *Request) loadAlias(name string) error {
+	r.Alias = name
+
+	return db.View(func(tx *bolt.Tx) error {
+		sb, err := r.ServiceBucket(tx)
 		if err != nil {
 			return err
 		}
@@ -214,28 +306,110 @@ func Perform(name string) {
 			return ErrNoAlias{Alias: name}
 		}
 
-		method := string(a.Get([]byte("method")))
-		path := string(a.Get([]byte("path")))
-		data := string(a.Get([]byte("data")))
+		r.Method = string(a.Get([]byte("method")))
+		r.Path = string(a.Get([]byte("path")))
+		r.Data = storedAliasData(a)
 
-		request.Method = method
-		request.Path = path
-		request.Data = data
+		return nil
+	})
+}
 
-		// get parameters from alias specific flags
-		for param := range aliasParams[name] {
-			if *aliasParams[name][param] != "" {
-				settings.Parameters[param] = *aliasParams[name][param]
-			}
+// storeAliasData stores the data given for the alias.  A reference to a file
+// is kept apart from literal data, along with the parameters found in the
+// file, so stored data starting with @ is never mistaken for a file and the
+// file isn't read on every run.
+func storeAliasData(a *bolt.Bucket, data string) error {
+	// bolt refuses to delete a missing key when the next key is a bucket
+	for _, key := range []string{"data", "data-file", "data-parameters"} {
+		if a.Get([]byte(key)) == nil {
+			continue
+		}
+		if err := a.Delete([]byte(key)); err != nil {
+			return err
 		}
+	}
+
+	switch {
+	case strings.HasPrefix(data, "@@"):
+		return a.Put([]byte("data"), []byte(data[1:]))
+	case !strings.HasPrefix(data, "@") || data == "@":
+		return a.Put([]byte("data"), []byte(data))
+	}
 
+	if err := a.Put([]byte("data-file"), []byte(data[1:])); err != nil {
+		return err
+	}
+
+	// if the file can't be read now it will be reported when the alias is used
+	buf, err := readData(data)
+	if err != nil {
 		return nil
-	})
+	}
+
+	var params []string
+	for p := range findParams(buf) {
+		params = append(params, p)
+	}
+	sort.Strings(params)
+
+	return a.Put([]byte("data-parameters"), []byte(strings.Join(params, ",")))
+}
+
+// storedAliasData returns the stored data the way it would be given on the
+// command line, a file reference starts with @ and literal data starting with
+// @ is escaped
+func storedAliasData(a *bolt.Bucket) string {
+	if file := a.Get([]byte("data-file")); file != nil {
+		return "@" + string(file)
+	}
+
+	data := string(a.Get([]byte("data")))
+	if strings.HasPrefix(data, "@") {
+		return "@" + data
+	}
+	return data
+}
+
+// importedAlias is an alias made from an api description or collection
+type importedAlias struct {
+	Name        string
+	Method      string
+	Path        string
+	Description string
+	Data        string
+	Settings    Settings
+}
 
+// write the alias into the aliases bucket.  Importing again replaces the
+// method, path, description, and data, but settings already stored for the
+// alias are kept so changes made by hand aren't lost.
+func (alias importedAlias) write(ab *bolt.Bucket) error {
+	a, err := ab.CreateBucketIfNotExists([]byte(alias.Name))
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		return err
+	}
+
+	if err := a.Put([]byte("method"), []byte(alias.Method)); err != nil {
+		return err
+	}
+
+	if err := a.Put([]byte("path"), []byte(alias.Path)); err != nil {
+		return err
+	}
+
+	if alias.Description != "" {
+		if err := a.Put([]byte("description"), []byte(alias.Description)); err != nil {
+			return err
+		}
+	}
+
+	if alias.Data != "" {
+		if err := storeAliasData(a, alias.Data); err != nil {
+			return err
+		}
 	}
 
-	Do(request.Method)
+	s := alias.Settings.Clone()
+	s.Merge(LoadSettings(a))
+	return s.Write(a)
 }
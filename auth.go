@@ -0,0 +1,374 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AuthProvider adds credentials to a prepared request
+type AuthProvider interface {
+	// Apply the credentials to the request, this is called again before
+	// every attempt so providers can refresh their credentials
+	Apply(req *http.Request) error
+
+	// Challenge is called when the service responds with 401, if it returns
+	// true the request will be sent again with the updated credentials
+	Challenge(req *http.Request, resp *http.Response) (bool, error)
+}
+
+// authProviders are all the available auth types, the constructor receives
+// the request, with its merged settings, and the parameter replacer
+var authProviders = map[string]func(r *Request, replace func(string) string) (AuthProvider, error){
+	"none":    newNoAuth,
+	"basic":   newBasicAuth,
+	"bearer":  newBearerAuth,
+	"api-key": newAPIKeyAuth,
+	"digest":  newDigestAuth,
+	"oauth2":  newOAuth2Auth,
+}
+
+type ErrAuthType struct {
+	Type string
+}
+
+func (e ErrAuthType) Error() string {
+	types := make([]string, 0, len(authProviders))
+	for t := range authProviders {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return fmt.Sprintf("unknown auth type %s, expected one of %s", e.Type, strings.Join(types, ", "))
+}
+
+type ErrAuthSetting struct {
+	Type    string
+	Setting string
+}
+
+func (e ErrAuthSetting) Error() string {
+	return fmt.Sprintf("%s auth requires %s to be set", e.Type, e.Setting)
+}
+
+// ErrAuthConflict is returned when two settings set the same header, one
+// would silently replace the credentials of the other
+type ErrAuthConflict struct {
+	Header string
+	First  string
+	Second string
+}
+
+func (e ErrAuthConflict) Error() string {
+	return fmt.Sprintf("the %s header is set by both %s and %s, remove one of them", e.Header, e.First, e.Second)
+}
+
+// authType returns the configured auth type, services that only have a
+// username and password set use basic auth
+func (s Settings) authType() string {
+	if s.AuthType.Valid && s.AuthType.String != "" {
+		return s.AuthType.String
+	}
+
+	if s.Username.String != "" && s.Password.String != "" {
+		return "basic"
+	}
+
+	return "none"
+}
+
+// Auth returns the auth provider described by the request settings
+func (r *Request) Auth(replace func(string) string) (AuthProvider, error) {
+	t := r.Settings.authType()
+	provider, ok := authProviders[t]
+	if !ok {
+		return nil, ErrAuthType{Type: t}
+	}
+
+	return provider(r, replace)
+}
+
+type noAuth struct{}
+
+func newNoAuth(*Request, func(string) string) (AuthProvider, error) {
+	return noAuth{}, nil
+}
+
+func (noAuth) Apply(*http.Request) error { return nil }
+
+func (noAuth) Challenge(*http.Request, *http.Response) (bool, error) { return false, nil }
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func newBasicAuth(r *Request, replace func(string) string) (AuthProvider, error) {
+	s := r.Settings
+	if s.Username.String == "" {
+		return nil, ErrAuthSetting{Type: "basic", Setting: "username"}
+	}
+
+	return basicAuth{
+		username: replace(s.Username.String),
+		password: replace(s.Password.String),
+	}, nil
+}
+
+func (a basicAuth) Apply(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (basicAuth) Challenge(*http.Request, *http.Response) (bool, error) { return false, nil }
+
+type bearerAuth struct {
+	token string
+}
+
+func newBearerAuth(r *Request, replace func(string) string) (AuthProvider, error) {
+	s := r.Settings
+	if s.AuthToken.String == "" {
+		return nil, ErrAuthSetting{Type: "bearer", Setting: "auth-token"}
+	}
+
+	return bearerAuth{token: replace(s.AuthToken.String)}, nil
+}
+
+func (a bearerAuth) Apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (bearerAuth) Challenge(*http.Request, *http.Response) (bool, error) { return false, nil }
+
+type apiKeyAuth struct {
+	name  string
+	key   string
+	query bool
+}
+
+func newAPIKeyAuth(r *Request, replace func(string) string) (AuthProvider, error) {
+	s := r.Settings
+	if s.AuthKeyName.String == "" {
+		return nil, ErrAuthSetting{Type: "api-key", Setting: "auth-key-name"}
+	}
+
+	if s.AuthKey.String == "" {
+		return nil, ErrAuthSetting{Type: "api-key", Setting: "auth-key"}
+	}
+
+	a := apiKeyAuth{
+		name: replace(s.AuthKeyName.String),
+		key:  replace(s.AuthKey.String),
+	}
+
+	switch s.AuthKeyIn.String {
+	case "", "header":
+	case "query":
+		a.query = true
+	default:
+		return nil, ErrAuthSetting{Type: "api-key", Setting: "auth-key-in to header or query"}
+	}
+
+	return a, nil
+}
+
+func (a apiKeyAuth) Apply(req *http.Request) error {
+	if a.query {
+		q := req.URL.Query()
+		q.Set(a.name, a.key)
+		req.URL.RawQuery = q.Encode()
+		return nil
+	}
+
+	req.Header.Set(a.name, a.key)
+	return nil
+}
+
+func (apiKeyAuth) Challenge(*http.Request, *http.Response) (bool, error) { return false, nil }
+
+// digestAuth implements RFC 7616 digest access authentication, the first
+// request is sent without credentials and the challenge from the 401
+// response is used to sign every request after that
+type digestAuth struct {
+	username string
+	password string
+
+	challenge map[string]string
+	count     int
+	cnonce    func() string
+}
+
+func newDigestAuth(r *Request, replace func(string) string) (AuthProvider, error) {
+	s := r.Settings
+	if s.Username.String == "" {
+		return nil, ErrAuthSetting{Type: "digest", Setting: "username"}
+	}
+
+	return &digestAuth{
+		username: replace(s.Username.String),
+		password: replace(s.Password.String),
+		cnonce:   randomHex,
+	}, nil
+}
+
+func (a *digestAuth) Apply(req *http.Request) error {
+	if a.challenge == nil {
+		return nil
+	}
+
+	a.count++
+	req.Header.Set("Authorization", a.authorization(req.Method, req.URL.RequestURI()))
+	return nil
+}
+
+func (a *digestAuth) Challenge(req *http.Request, resp *http.Response) (bool, error) {
+	header := resp.Header.Get("WWW-Authenticate")
+	if !strings.HasPrefix(strings.ToLower(header), "digest ") {
+		return false, nil
+	}
+
+	challenge := parseAuthParams(header[len("digest "):])
+
+	// we already answered this challenge and it was rejected, so the
+	// credentials are wrong
+	if a.challenge != nil && a.challenge["nonce"] == challenge["nonce"] &&
+		!strings.EqualFold(challenge["stale"], "true") {
+		return false, nil
+	}
+
+	a.challenge = challenge
+	a.count = 0
+
+	return true, nil
+}
+
+func (a *digestAuth) authorization(method, uri string) string {
+	c := a.challenge
+	algorithm := c["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var h func() hash.Hash
+	switch strings.ToUpper(strings.TrimSuffix(algorithm, "-sess")) {
+	case "SHA-256":
+		h = sha256.New
+	default:
+		h = md5.New
+	}
+
+	digest := func(parts ...string) string {
+		d := h()
+		d.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	nc := fmt.Sprintf("%08x", a.count)
+	cnonce := a.cnonce()
+
+	ha1 := digest(a.username, c["realm"], a.password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = digest(ha1, c["nonce"], cnonce)
+	}
+	ha2 := digest(method, uri)
+
+	// only auth quality of protection is supported
+	qop := ""
+	for _, q := range strings.Split(c["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	var response string
+	if qop == "" {
+		response = digest(ha1, c["nonce"], ha2)
+	} else {
+		response = digest(ha1, c["nonce"], nc, cnonce, qop, ha2)
+	}
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, a.username),
+		fmt.Sprintf(`realm="%s"`, c["realm"]),
+		fmt.Sprintf(`nonce="%s"`, c["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+		fmt.Sprintf(`algorithm=%s`, algorithm),
+	}
+
+	if qop != "" {
+		params = append(params,
+			fmt.Sprintf("qop=%s", qop),
+			fmt.Sprintf("nc=%s", nc),
+			fmt.Sprintf(`cnonce="%s"`, cnonce),
+		)
+	}
+
+	if opaque, ok := c["opaque"]; ok {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+
+	return "Digest " + strings.Join(params, ", ")
+}
+
+// parseAuthParams parses the comma separated key=value pairs of an
+// authentication challenge, values may be quoted
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+
+		params[key] = value
+	}
+
+	return params
+}
+
+func randomHex() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(buf)
+}
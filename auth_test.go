@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestDigestAuthorization(t *testing.T) {
+	// example from RFC 2617 section 3.5
+	a := &digestAuth{
+		username: "Mufasa",
+		password: "Circle Of Life",
+		cnonce:   func() string { return "0a4f113b" },
+	}
+
+	resp := &http.Response{Header: make(http.Header)}
+	resp.Header.Set("WWW-Authenticate", `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+
+	again, err := a.Challenge(nil, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again {
+		t.Fatal("expected digest challenge to be answered")
+	}
+
+	req, err := http.NewRequest("GET", "http://www.nowhere.org/dir/index.html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := req.Header.Get("Authorization")
+	for _, expected := range []string{
+		`response="6629fae49393a05397450978507c4ef1"`,
+		`nc=00000001`,
+		`opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+	} {
+		if !strings.Contains(auth, expected) {
+			t.Errorf("expected %s in %s", expected, auth)
+		}
+	}
+
+	// the same challenge again means the credentials were rejected
+	if again, _ := a.Challenge(nil, resp); again {
+		t.Error("expected rejected credentials not to be retried")
+	}
+}
+
+func TestRedactSetting(t *testing.T) {
+	tests := []struct {
+		bucket, key, value, expected string
+	}{
+		{"", "password", "hunter2", "<redacted>"},
+		{"", "password", "$PASSWORD", "$PASSWORD"},
+		{"auth", "token", "abc", "<redacted>"},
+		{"auth", "token", ":token", ":token"},
+		{"auth", "token", "{{token}}", "{{token}}"},
+		{"auth", "token", "${TOKEN}", "${TOKEN}"},
+		{"auth", "token", "$TOKEN-abc", "<redacted>"},
+		{"auth", "token", ":token abc", "<redacted>"},
+		{"auth", "token", "{{token}}abc", "<redacted>"},
+		{"auth", "type", "bearer", "bearer"},
+		{"headers", "Accept", "application/json", "application/json"},
+	}
+
+	for _, test := range tests {
+		if v := string(redactSetting(test.bucket, test.key, []byte(test.value))); v != test.expected {
+			t.Errorf("%s.%s: expected %s got %s", test.bucket, test.key, test.expected, v)
+		}
+	}
+}
+
+func TestDisplayServiceKeyRedacts(t *testing.T) {
+	defer testDB(t, "secret")()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := getBucket(tx, "services.secret")
+		for key, value := range map[string]string{
+			"auth.token":                 "token-value",
+			"auth.key":                   "key-value",
+			"auth.client-secret":         "client-secret-value",
+			"signing.secret":             "signing-secret-value",
+			"signing.type":               "hmac",
+			"oauth2.client.access-token": "access-token-value",
+		} {
+			if err := write(b, key, &value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, secret string
+	}{
+		{"auth", "token-value"},
+		{"auth", "key-value"},
+		{"auth", "client-secret-value"},
+		{"signing", "signing-secret-value"},
+		// tokens are cached in a bucket for each client
+		{"oauth2", "access-token-value"},
+		{"oauth2.client", "access-token-value"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		db.View(func(tx *bolt.Tx) error {
+			displayServiceKey(&buf, getBucket(tx, "services.secret"), "secret", test.key)
+			return nil
+		})
+
+		if strings.Contains(buf.String(), test.secret) {
+			t.Errorf("config %s shows %s:\n%s", test.key, test.secret, buf.String())
+		}
+		if !strings.Contains(buf.String(), "<redacted>") {
+			t.Errorf("config %s expected a redacted value:\n%s", test.key, buf.String())
+		}
+	}
+}
+
+func TestAuthConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings func(s *Settings)
+		conflict bool
+	}{
+		{"bearer", func(s *Settings) {
+			s.AuthType = sql.NullString{String: "bearer", Valid: true}
+			s.AuthToken = sql.NullString{String: "token", Valid: true}
+		}, false},
+		{"stored header and bearer", func(s *Settings) {
+			s.Headers["Authorization"] = "Bearer stored"
+			s.AuthType = sql.NullString{String: "bearer", Valid: true}
+			s.AuthToken = sql.NullString{String: "token", Valid: true}
+		}, true},
+		{"stored header without auth", func(s *Settings) {
+			s.Headers["Authorization"] = "Bearer stored"
+		}, false},
+		{"basic and hmac signing", func(s *Settings) {
+			s.Username = sql.NullString{String: "user", Valid: true}
+			s.Password = sql.NullString{String: "pass", Valid: true}
+			s.SigningType = sql.NullString{String: "hmac", Valid: true}
+			s.SigningKeyID = sql.NullString{String: "id", Valid: true}
+			s.SigningSecret = sql.NullString{String: "secret", Valid: true}
+		}, true},
+		{"basic and hmac signing another header", func(s *Settings) {
+			s.Username = sql.NullString{String: "user", Valid: true}
+			s.Password = sql.NullString{String: "pass", Valid: true}
+			s.SigningType = sql.NullString{String: "hmac", Valid: true}
+			s.SigningKeyID = sql.NullString{String: "id", Valid: true}
+			s.SigningSecret = sql.NullString{String: "secret", Valid: true}
+			s.SigningHeader = sql.NullString{String: "X-Signature", Valid: true}
+		}, false},
+	}
+
+	for _, test := range tests {
+		r := Request{Method: "get", Path: "users", Settings: defaultSettings.Clone()}
+		test.settings(&r.Settings)
+
+		_, err := r.Prepare()
+		if _, ok := err.(ErrAuthConflict); ok != test.conflict {
+			t.Errorf("%s: expected conflict %v got %v", test.name, test.conflict, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	batchAlias       string
+	batchInput       string
+	batchFormat      string
+	batchConcurrency int
+)
+
+func init() {
+	batch.Arg("alias", "the alias to run for every row").Required().StringVar(&batchAlias)
+	batch.Flag("input", "csv or json lines file with a row for every request, - reads from stdin").
+		Required().
+		StringVar(&batchInput)
+	batch.Flag("format", "format of the input, either csv or jsonl, guessed from the file when not set").
+		EnumVar(&batchFormat, "csv", "jsonl")
+	batch.Flag("concurrency", "how many requests to perform at once").
+		Default("4").
+		IntVar(&batchConcurrency)
+
+	requestFlags(batch, false)
+}
+
+// ErrBatch is returned when some of the rows failed
+type ErrBatch struct {
+	Failed int
+	Total  int
+}
+
+func (e ErrBatch) Error() string {
+	return fmt.Sprintf("%d of %d rows failed", e.Failed, e.Total)
+}
+
+// batchResult is written as a json line for every row
+type batchResult struct {
+	Row    int             `json:"row"`
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Error  string          `json:"error,omitempty"`
+
+	dryRun bool
+}
+
+func (r batchResult) failed() bool {
+	return r.Error != "" || r.Status < 200 || r.Status > 299
+}
+
+// Batch runs the alias for every row in the input, the rows are numbered
+// from 1 and the results are written as they finish
+func Batch() error {
+	in := os.Stdin
+	if batchInput != "-" {
+		var err error
+		in, err = os.Open(batchInput)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	rows, err := readRows(in, batchFormat, batchInput)
+	if err != nil {
+		return err
+	}
+
+	// catch a missing alias before making any requests
+	if _, err := aliasRequest(batchAlias, nil); err != nil {
+		return err
+	}
+	warnUnknownColumns(batchAlias, rows)
+
+	failed := runBatch(rows, batchConcurrency, os.Stdout, runRow)
+
+	for _, f := range failed {
+		if f.Error != "" {
+			log.Printf("row %d: %s\n", f.Row, f.Error)
+		} else {
+			log.Printf("row %d: status %d\n", f.Row, f.Status)
+		}
+	}
+
+	if len(failed) > 0 {
+		return ErrBatch{Failed: len(failed), Total: len(rows)}
+	}
+
+	return nil
+}
+
+// runBatch performs at most concurrency rows at once, and returns the rows
+// that failed in order
+func runBatch(rows []map[string]string, concurrency int, out io.Writer, run func(int, map[string]string) batchResult) []batchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	results := make(chan batchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- run(i+1, rows[i])
+			}
+		}()
+	}
+
+	go func() {
+		for i := range rows {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var failed []batchResult
+	enc := json.NewEncoder(out)
+	for result := range results {
+		if result.dryRun {
+			continue
+		}
+
+		if err := enc.Encode(result); err != nil {
+			result.Error = err.Error()
+		}
+
+		if result.failed() {
+			failed = append(failed, result)
+		}
+	}
+
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Row < failed[j].Row })
+	return failed
+}
+
+// runRow performs the alias with the row as its parameters
+func runRow(row int, params map[string]string) batchResult {
+	result := batchResult{Row: row}
+
+	r, err := aliasRequest(batchAlias, params)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// events are collected instead of being written as they arrive
+	var events bytes.Buffer
+	response := Response{out: &events}
+
+	if err := r.Execute(&response); err != nil {
+		result.dryRun = err == ErrDryRun
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = response.resp.StatusCode
+
+	body := response.display
+	if response.streamed {
+		body = events.Bytes()
+	}
+	result.Body = jsonBody(body)
+
+	return result
+}
+
+// jsonBody keeps json bodies as they are, anything else becomes a string
+func jsonBody(body []byte) json.RawMessage {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		return compact.Bytes()
+	}
+
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
+
+// readRows reads every row of the input into parameters.  CSV files use the
+// header for the parameter names, json lines use the object keys.
+func readRows(in io.Reader, format, name string) ([]map[string]string, error) {
+	reader := bufio.NewReader(in)
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".csv":
+			format = "csv"
+		case ".jsonl", ".ndjson", ".json":
+			format = "jsonl"
+		default:
+			// json lines always start with an object
+			format = "csv"
+			if start, err := reader.Peek(1); err == nil && start[0] == '{' {
+				format = "jsonl"
+			}
+		}
+	}
+
+	if format == "jsonl" {
+		return readJSONRows(reader)
+	}
+
+	return readCSVRows(reader)
+}
+
+func readCSVRows(in io.Reader) ([]map[string]string, error) {
+	records, err := csv.NewReader(in).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string)
+		for i, value := range record {
+			row[strings.TrimSpace(header[i])] = value
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readJSONRows(in io.Reader) ([]map[string]string, error) {
+	var rows []map[string]string
+
+	dec := json.NewDecoder(in)
+	dec.UseNumber()
+	for {
+		var values map[string]interface{}
+		if err := dec.Decode(&values); err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("row %d: %s", len(rows)+1, err)
+		}
+
+		row := make(map[string]string)
+		for key, value := range values {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				row[key] = v
+			case json.Number, bool:
+				row[key] = fmt.Sprint(v)
+			default:
+				encoded, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				row[key] = string(encoded)
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// warnUnknownColumns points out columns that don't match an alias parameter,
+// they are still set as parameters in case they are used by a hook
+func warnUnknownColumns(alias string, rows []map[string]string) {
+	params, ok := aliasParams[alias]
+	if !ok || len(rows) == 0 {
+		return
+	}
+
+	var unknown []string
+	for column := range rows[0] {
+		if _, ok := params[column]; !ok {
+			unknown = append(unknown, column)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, column := range unknown {
+		log.Printf("column %s is not a parameter of %s\n", column, alias)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestReadRows(t *testing.T) {
+	csvRows, err := readRows(strings.NewReader("id, name\n1,one\n2,two\n"), "", "rows.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonRows, err := readRows(strings.NewReader(`{"id": 1, "name": "one"}`+"\n"+`{"id": 2, "name": "two", "skip": null}`), "", "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rows := range [][]map[string]string{csvRows, jsonRows} {
+		if len(rows) != 2 || rows[0]["id"] != "1" || rows[1]["name"] != "two" {
+			t.Errorf("unexpected rows %v", rows)
+		}
+		if _, ok := rows[1]["skip"]; ok {
+			t.Error("null values should not be set")
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	defer testDB(t, "batch")()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := strings.TrimPrefix(req.URL.Path, "/users/")
+		if id == "404" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "lua": req.Header.Get("X-Lua")})
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host, port, _ := strings.Cut(u.Host, ":")
+	p, _ := strconv.Atoi(port)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		s := NewSettings()
+		s.Scheme = sql.NullString{String: "http", Valid: true}
+		s.Host = sql.NullString{String: host, Valid: true}
+		s.Port = sql.NullInt64{Int64: int64(p), Valid: true}
+		if err := s.Write(getBucket(tx, "services.batch")); err != nil {
+			return err
+		}
+
+		a, err := getBucket(tx, "services.batch").CreateBucketIfNotExists([]byte("aliases"))
+		if err != nil {
+			return err
+		}
+		user, err := a.CreateBucket([]byte("user"))
+		if err != nil {
+			return err
+		}
+		user.Put([]byte("method"), []byte("get"))
+		user.Put([]byte("path"), []byte("users/:id"))
+
+		// each row has its own lua environment, so the hook sees its own row
+		alias := NewSettings()
+		alias.RequestHook = sql.NullString{String: `seen = (seen or "") .. request.path; request.headers["X-Lua"] = seen`, Valid: true}
+		alias.Filter = sql.NullString{String: "[id, lua]", Valid: true}
+		return alias.Write(user)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Service = "batch"
+	defer func() { request.Service = "" }()
+	batchAlias = "user"
+
+	var rows []map[string]string
+	for i := 1; i <= 20; i++ {
+		rows = append(rows, map[string]string{"id": strconv.Itoa(i)})
+	}
+	rows = append(rows, map[string]string{"id": "404"})
+
+	var out bytes.Buffer
+	failed := runBatch(rows, 5, &out, runRow)
+
+	if len(failed) != 1 || failed[0].Row != 21 || failed[0].Status != http.StatusNotFound {
+		t.Errorf("expected row 21 to fail, got %+v", failed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(rows) {
+		t.Fatalf("expected %d results, got %d", len(rows), len(lines))
+	}
+
+	for _, line := range lines {
+		var result batchResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatal(err)
+		}
+
+		id := rows[result.Row-1]["id"]
+		expected := `["` + id + `","users/` + id + `"]`
+		if string(result.Body) != expected {
+			t.Errorf("row %d: expected %s got %s", result.Row, expected, result.Body)
+		}
+	}
+}
+
+func TestSetParametersInRequestService(t *testing.T) {
+	defer testDB(t, "current")()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"token": "abc"})
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host, port, _ := strings.Cut(u.Host, ":")
+	p, _ := strconv.Atoi(port)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte("info")).Put([]byte("current"), []byte("current")); err != nil {
+			return err
+		}
+
+		b, err := tx.Bucket([]byte("services")).CreateBucket([]byte("other"))
+		if err != nil {
+			return err
+		}
+
+		s := NewSettings()
+		s.Scheme = sql.NullString{String: "http", Valid: true}
+		s.Host = sql.NullString{String: host, Valid: true}
+		s.Port = sql.NullInt64{Int64: int64(p), Valid: true}
+		return s.Write(b)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Request{Service: "other", Method: "get", Path: "login", Flags: NewSettings()}
+	r.Flags.SetParameters["token"] = "token"
+	var resp Response
+	if err := r.Execute(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	db.View(func(tx *bolt.Tx) error {
+		if v := getBucket(tx, "services.other.parameters"); v == nil || string(v.Get([]byte("token"))) == "" {
+			t.Error("expected the parameter to be set in the service the request was made to")
+		}
+		if v := getBucket(tx, "services.current.parameters"); v != nil && v.Get([]byte("token")) != nil {
+			t.Error("expected the current service to be unchanged")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	benchTarget      []string
+	benchRequests    int
+	benchConcurrency int
+	benchDuration    time.Duration
+	benchRate        float64
+	benchFormat      string
+)
+
+func init() {
+	benchmark.Arg("target", "an alias, or a method and path followed by optional data").
+		Required().
+		StringsVar(&benchTarget)
+	benchmark.Flag("requests", "how many requests to send, defaults to 100 when no duration is given").
+		IntVar(&benchRequests)
+	benchmark.Flag("concurrency", "how many requests to have in flight at once").
+		Default("10").
+		IntVar(&benchConcurrency)
+	benchmark.Flag("duration", "how long to keep sending requests for").
+		DurationVar(&benchDuration)
+	benchmark.Flag("rate", "the most requests to send per second, by default requests are sent as fast as possible").
+		Float64Var(&benchRate)
+	benchmark.Flag("format", "format of the report, either text or json").
+		Default("text").
+		EnumVar(&benchFormat, "text", "json")
+
+	requestFlags(benchmark, false)
+}
+
+// number of bars in the latency histogram
+const histogramBuckets = 10
+
+type benchOptions struct {
+	requests    int
+	concurrency int
+	duration    time.Duration
+	rate        float64
+}
+
+type benchLatency struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+type benchBucket struct {
+	From  float64 `json:"from_ms"`
+	To    float64 `json:"to_ms"`
+	Count int     `json:"count"`
+}
+
+type benchReport struct {
+	Requests   int            `json:"requests"`
+	Duration   float64        `json:"duration_seconds"`
+	Throughput float64        `json:"requests_per_second"`
+	Statuses   map[int]int    `json:"statuses"`
+	Errors     map[string]int `json:"errors,omitempty"`
+	Latency    benchLatency   `json:"latency"`
+	Histogram  []benchBucket  `json:"histogram"`
+}
+
+// benchResults are collected by each worker and combined at the end
+type benchResults struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+}
+
+// Bench load tests the alias or request and writes the report to stdout
+func Bench() error {
+	var r *Request
+	switch len(benchTarget) {
+	case 1:
+		var err error
+		r, err = aliasRequest(benchTarget[0], nil)
+		if err != nil {
+			return err
+		}
+	case 2, 3:
+		r = newRequest()
+		r.Method = benchTarget[0]
+		r.Path = benchTarget[1]
+		if len(benchTarget) == 3 {
+			r.Data = benchTarget[2]
+		}
+	default:
+		return fmt.Errorf("expected an alias, or a method and path, got %s", strings.Join(benchTarget, " "))
+	}
+
+	report, err := r.Bench(benchOptions{
+		requests:    benchRequests,
+		concurrency: benchConcurrency,
+		duration:    benchDuration,
+		rate:        benchRate,
+	})
+	if err == ErrDryRun {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return report.Write(os.Stdout, benchFormat)
+}
+
+// Bench prepares the request once, including running the request hooks and
+// parameter replacement, then sends copies of it until enough requests have
+// been sent or the duration is up.  Retries are not used so that every
+// failure is counted.
+func (r *Request) Bench(opts benchOptions) (benchReport, error) {
+	env := &luaEnv{}
+	defer env.Close()
+	r.lua = env
+
+	if err := r.LoadData(); err != nil {
+		return benchReport{}, err
+	}
+
+	if err := db.Update(r.LoadSettings); err != nil {
+		return benchReport{}, err
+	}
+
+	template, err := r.Prepare()
+	if err != nil {
+		return benchReport{}, err
+	}
+
+	if r.DryRun {
+		if err := r.sign(template); err != nil {
+			return benchReport{}, err
+		}
+		dump, err := httputil.DumpRequestOut(template, true)
+		if err != nil {
+			return benchReport{}, err
+		}
+		fmt.Println(string(dump))
+		return benchReport{}, ErrDryRun
+	}
+
+	client, err := r.Settings.Client()
+	if err != nil {
+		return benchReport{}, err
+	}
+
+	if opts.concurrency < 1 {
+		opts.concurrency = 1
+	}
+	if opts.requests == 0 && opts.duration == 0 {
+		opts.requests = 100
+	}
+
+	// keep a connection open for every worker
+	if t, ok := client.Transport.(*http.Transport); ok {
+		t.MaxIdleConnsPerHost = opts.concurrency
+	}
+
+	ctx := context.Background()
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	jobs := make(chan struct{})
+	go func() {
+		defer close(jobs)
+
+		var tick <-chan time.Time
+		if opts.rate > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i := 0; opts.requests == 0 || i < opts.requests; i++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case jobs <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([]benchResults, opts.concurrency)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(results *benchResults) {
+			defer wg.Done()
+			results.statuses = make(map[int]int)
+			results.errors = make(map[string]int)
+
+			for range jobs {
+				req := template.Clone(context.Background())
+				latency, status, err := r.benchOnce(client, req)
+				if err != nil {
+					results.errors[benchErrorClass(err)]++
+					continue
+				}
+				results.statuses[status]++
+				results.latencies = append(results.latencies, latency)
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return newBenchReport(results, time.Since(start)), nil
+}
+
+// benchErrorClass groups the errors in the report, errors that can't be
+// retried don't have a class of their own
+func benchErrorClass(err error) string {
+	if class := errorClass(err); class != "" {
+		return class
+	}
+	return "other"
+}
+
+// benchOnce sends the request and reads the whole response.  The service rate
+// limit isn't applied, the bench rate controls how fast requests are sent.
+func (r *Request) benchOnce(client *http.Client, req *http.Request) (time.Duration, int, error) {
+	if _, err := rewind(req); err != nil {
+		return 0, 0, err
+	}
+
+	// signatures may include the time, so every request is signed
+	if err := r.sign(req); err != nil {
+		return 0, 0, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return time.Since(start), resp.StatusCode, nil
+}
+
+func newBenchReport(results []benchResults, elapsed time.Duration) benchReport {
+	report := benchReport{
+		Duration: elapsed.Seconds(),
+		Statuses: make(map[int]int),
+		Errors:   make(map[string]int),
+	}
+
+	var latencies []time.Duration
+	for _, r := range results {
+		latencies = append(latencies, r.latencies...)
+		for status, count := range r.statuses {
+			report.Statuses[status] += count
+			report.Requests += count
+		}
+		for class, count := range r.errors {
+			report.Errors[class] += count
+			report.Requests += count
+		}
+	}
+
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+
+	if len(latencies) == 0 {
+		return report
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+
+	report.Latency = benchLatency{
+		Min:  ms(latencies[0]),
+		Mean: ms(total / time.Duration(len(latencies))),
+		P50:  ms(percentile(latencies, 50)),
+		P90:  ms(percentile(latencies, 90)),
+		P99:  ms(percentile(latencies, 99)),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+	report.Histogram = histogram(latencies)
+
+	return report
+}
+
+// percentile uses the nearest rank of the sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// histogram splits the range of sorted latencies into equal buckets
+func histogram(sorted []time.Duration) []benchBucket {
+	min, max := sorted[0], sorted[len(sorted)-1]
+	width := (max - min) / histogramBuckets
+	if width <= 0 {
+		return []benchBucket{{From: ms(min), To: ms(max), Count: len(sorted)}}
+	}
+
+	buckets := make([]benchBucket, histogramBuckets)
+	for i := range buckets {
+		buckets[i].From = ms(min + time.Duration(i)*width)
+		buckets[i].To = ms(min + time.Duration(i+1)*width)
+	}
+	buckets[len(buckets)-1].To = ms(max)
+
+	for _, l := range sorted {
+		i := int((l - min) / width)
+		if i >= len(buckets) {
+			i = len(buckets) - 1
+		}
+		buckets[i].Count++
+	}
+
+	return buckets
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Write the report as text or json
+func (b benchReport) Write(w io.Writer, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(b)
+	}
+
+	fmt.Fprintf(w, "requests:   %d in %.2fs, %.1f/s\n", b.Requests, b.Duration, b.Throughput)
+
+	statuses := make([]int, 0, len(b.Statuses))
+	for status := range b.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	counts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		counts = append(counts, fmt.Sprintf("%d x %d", status, b.Statuses[status]))
+	}
+	fmt.Fprintf(w, "statuses:   %s\n", strings.Join(counts, ", "))
+
+	if len(b.Errors) > 0 {
+		classes := make([]string, 0, len(b.Errors))
+		for class, count := range b.Errors {
+			classes = append(classes, fmt.Sprintf("%s x %d", class, count))
+		}
+		sort.Strings(classes)
+		fmt.Fprintf(w, "errors:     %s\n", strings.Join(classes, ", "))
+	}
+
+	l := b.Latency
+	fmt.Fprintf(w, "latency:    min %.2fms, mean %.2fms, p50 %.2fms, p90 %.2fms, p99 %.2fms, max %.2fms\n",
+		l.Min, l.Mean, l.P50, l.P90, l.P99, l.Max)
+
+	most := 0
+	for _, bucket := range b.Histogram {
+		if bucket.Count > most {
+			most = bucket.Count
+		}
+	}
+
+	for _, bucket := range b.Histogram {
+		bar := 0
+		if most > 0 {
+			bar = bucket.Count * 40 / most
+		}
+		fmt.Fprintf(w, "%10.2fms - %10.2fms  %-40s %d\n", bucket.From, bucket.To, strings.Repeat("#", bar), bucket.Count)
+	}
+
+	return nil
+}
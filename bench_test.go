@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	for p, expected := range map[float64]time.Duration{50: 50 * time.Millisecond, 90: 90 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond} {
+		if result := percentile(latencies, p); result != expected {
+			t.Errorf("p%v: expected %s got %s", p, expected, result)
+		}
+	}
+
+	buckets := histogram(latencies)
+	total := 0
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if len(buckets) != histogramBuckets || total != len(latencies) {
+		t.Errorf("unexpected histogram %v", buckets)
+	}
+}
+
+func TestBench(t *testing.T) {
+	defer testDB(t, "bench")()
+
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		if req.URL.Path != "/items/7" || req.Header.Get("X-Hooked") != "yes" {
+			t.Errorf("unexpected request %s %v", req.URL, req.Header)
+		}
+		if n%5 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host, port, _ := strings.Cut(u.Host, ":")
+	p, _ := strconv.Atoi(port)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		s := NewSettings()
+		s.Scheme = sql.NullString{String: "http", Valid: true}
+		s.Host = sql.NullString{String: host, Valid: true}
+		s.Port = sql.NullInt64{Int64: int64(p), Valid: true}
+		s.Parameters["id"] = "7"
+		s.RequestHook = sql.NullString{String: `request.headers["X-Hooked"] = "yes"`, Valid: true}
+		return s.Write(getBucket(tx, "services.bench"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Request{Service: "bench", Method: "get", Path: "items/:id"}
+	report, err := r.Bench(benchOptions{requests: 50, concurrency: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != 50 || report.Statuses[200] != 40 || report.Statuses[503] != 10 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report.Latency.P50 > report.Latency.P99 || report.Latency.Max == 0 {
+		t.Errorf("unexpected latency %+v", report.Latency)
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded benchReport
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Statuses[503] != 10 {
+		t.Errorf("unexpected json report %s", out.String())
+	}
+
+	// the rate limit spaces the requests out
+	start := time.Now()
+	r = Request{Service: "bench", Method: "get", Path: "items/:id"}
+	report, err = r.Bench(benchOptions{requests: 5, concurrency: 5, rate: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || report.Requests != 5 {
+		t.Errorf("expected 5 requests to take at least 80ms at 50/s, took %s for %d", elapsed, report.Requests)
+	}
+
+	// without a request count the duration decides when to stop
+	r = Request{Service: "bench", Method: "get", Path: "items/:id"}
+	report, err = r.Bench(benchOptions{duration: 50 * time.Millisecond, concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.Duration > 1 {
+		t.Errorf("unexpected duration report %+v", report)
+	}
+
+	// errors without a class are still named in the report
+	r = Request{Service: "bench", Method: "get", Path: "items/:id", Flags: NewSettings()}
+	r.Flags.Scheme = sql.NullString{String: "ftp", Valid: true}
+	report, err = r.Bench(benchOptions{requests: 3, concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Errors["other"] != 3 {
+		t.Errorf("expected unsupported protocol errors to be other, got %v", report.Errors)
+	}
+}
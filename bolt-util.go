@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
+	"os"
 	"strconv"
 	"strings"
 
@@ -82,15 +84,21 @@ func unsetBucket(b *bolt.Bucket, key string) error {
 }
 
 func printBucket(b *bolt.Bucket, level int) {
+	printNestedBucket(os.Stdout, b, "", level)
+}
+
+// printNestedBucket prints the bucket, secret settings are redacted using the
+// name of the bucket they are stored in
+func printNestedBucket(w io.Writer, b *bolt.Bucket, name string, level int) {
 	padding := strings.Repeat(" ", level*4)
 	c := b.Cursor()
 	for key, value := c.First(); key != nil; key, value = c.Next() {
 		if value == nil {
 			nested := b.Bucket(key)
-			fmt.Printf("%s%s:\n", padding, string(key))
-			printBucket(nested, level+1)
+			fmt.Fprintf(w, "%s%s:\n", padding, string(key))
+			printNestedBucket(w, nested, secretBucket(name, string(key)), level+1)
 		} else {
-			fmt.Printf("%s%s: %s\n", padding, key, value)
+			fmt.Fprintf(w, "%s%s: %s\n", padding, key, redactSetting(name, string(key), value))
 		}
 	}
 }
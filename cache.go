@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// cached responses for every service are kept in this top level bucket
+const cacheBucket = "cache"
+
+// ErrNotCached is returned when offline and there is no cached response for the request
+type ErrNotCached struct {
+	Method string
+	URL    string
+}
+
+func (e ErrNotCached) Error() string {
+	return fmt.Sprintf("offline and no cached response for %s %s", e.Method, e.URL)
+}
+
+// cacheEntry is a stored response along with when it was stored, which
+// decides how long it is fresh for
+type cacheEntry struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Stored     time.Time   `json:"stored"`
+
+	// Vary is the request headers named by the Vary header of the response,
+	// the entry is only used for requests that have the same values
+	Vary http.Header `json:"vary,omitempty"`
+}
+
+// send performs the request, going through the response cache when it is
+// enabled.  Only GET requests are cached, and responses streamed to an
+// output file aren't stored as that would hold the whole body in memory.
+func (r *Request) send(req *http.Request) (*http.Response, error) {
+	offline := r.Settings.Offline.Bool
+	if !r.Settings.Cache.Bool && !offline {
+		return r.retry(req)
+	}
+
+	if r.Settings.OutputFile.String != "" && !offline {
+		r.logCache("not caching response written to %s", r.Settings.OutputFile.String)
+		return r.retry(req)
+	}
+
+	if req.Method != http.MethodGet {
+		if offline {
+			return nil, ErrNotCached{Method: req.Method, URL: req.URL.String()}
+		}
+		return r.retry(req)
+	}
+
+	key, err := r.cacheKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := loadCacheEntry(r.Service, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && !entry.matches(req) {
+		r.logCache("cached response varies from the request")
+		entry = nil
+	}
+
+	now := time.Now()
+	switch {
+	case offline && entry == nil:
+		return nil, ErrNotCached{Method: req.Method, URL: req.URL.String()}
+	case offline:
+		r.logCache("serving cached response, stored %s", entry.Stored.Format(time.RFC1123))
+		return entry.response(req), nil
+	case entry != nil && entry.fresh(req, now):
+		r.logCache("serving fresh cached response")
+		return entry.response(req), nil
+	}
+
+	// the validators belong to the cached entry, a cloned page request may
+	// still have the ones from the previous page
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if entry != nil {
+		entry.validate(req)
+	}
+
+	resp, err := r.retry(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		r.logCache("not modified, serving cached response")
+		entry.refresh(resp.Header, now)
+		if err := storeCacheEntry(r.Service, key, entry); err != nil {
+			return nil, err
+		}
+		return entry.response(req), nil
+	}
+
+	// streams never end, so there is nothing to store
+	if resp.StatusCode != http.StatusOK || streamKind(resp) != "" {
+		return resp, nil
+	}
+
+	if noStore(req.Header) || noStore(resp.Header) {
+		r.logCache("not storing response, no-store")
+		return resp, deleteCacheEntry(r.Service, key)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry = &cacheEntry{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Stored:     now,
+	}
+
+	if !entry.storeVary(req) {
+		r.logCache("not storing response, vary *")
+		return resp, deleteCacheEntry(r.Service, key)
+	}
+
+	r.logCache("storing response")
+	return resp, storeCacheEntry(r.Service, key, entry)
+}
+
+func (r *Request) logCache(format string, v ...interface{}) {
+	if r.verbose > 0 {
+		log.Printf("cache: "+format+"\n", v...)
+	}
+}
+
+// cacheKey identifies the prepared request by its method, url, headers,
+// credentials, and body.  The credentials are identified by the settings
+// they come from rather than the applied headers or api key query, so a
+// refreshed token still finds the cached response and offline requests,
+// which aren't authenticated, don't need them.
+func (r *Request) cacheKey(req *http.Request) (string, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	// the query is encoded the same way whether or not the api key was added
+	u := *req.URL
+	q := u.Query()
+	if r.Settings.authType() == "api-key" && r.Settings.AuthKeyIn.String == "query" {
+		q.Del(replacer(r.Settings.Parameters)(r.Settings.AuthKeyName.String))
+	}
+	u.RawQuery = q.Encode()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", req.Method, u.String())
+	fmt.Fprintf(h, "credentials: %s\n", r.credentials)
+
+	keys := make([]string, 0, len(r.keyHeader))
+	for key := range r.keyHeader {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s: %s\n", key, strings.Join(r.keyHeader[key], ", "))
+	}
+
+	h.Write([]byte("\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// credentials is a hash of the settings that identify who the request is
+// made as, responses fetched as one identity are never served to another
+func (s Settings) credentials(replace func(string) string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", s.authType())
+
+	for _, setting := range []sql.NullString{
+		s.Username, s.Password,
+		s.AuthToken,
+		s.AuthKeyName, s.AuthKey, s.AuthKeyIn,
+		s.AuthTokenURL, s.AuthClientID, s.AuthGrant, s.AuthScopes, s.AuthRefreshToken,
+		s.SigningType, s.SigningKeyID,
+	} {
+		fmt.Fprintf(h, "%s\n", replace(setting.String))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// matches reports if the entry can be used for the request, the headers the
+// response varies by must be the same as when it was stored.  Authorization
+// is already part of the cache key, and isn't set on offline requests.
+func (e cacheEntry) matches(req *http.Request) bool {
+	for key, values := range e.Vary {
+		if key == "Authorization" {
+			continue
+		}
+
+		if strings.Join(req.Header.Values(key), ", ") != strings.Join(values, ", ") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// storeVary keeps the request headers the response varies by, it returns
+// false when the response varies by something other than headers
+func (e *cacheEntry) storeVary(req *http.Request) bool {
+	for _, value := range e.Header.Values("Vary") {
+		for _, key := range strings.Split(value, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			switch key {
+			case "":
+				continue
+			case "*":
+				return false
+			}
+
+			if e.Vary == nil {
+				e.Vary = make(http.Header)
+			}
+			e.Vary[key] = req.Header.Values(key)
+		}
+	}
+
+	return true
+}
+
+// fresh reports if the entry can be used without asking the service, which
+// is only while it is younger than its max-age
+func (e cacheEntry) fresh(req *http.Request, now time.Time) bool {
+	if _, ok := cacheControl(req.Header)["no-cache"]; ok {
+		return false
+	}
+
+	directives := cacheControl(e.Header)
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+
+	maxAge, err := strconv.Atoi(directives["max-age"])
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(e.Stored) < time.Duration(maxAge)*time.Second
+}
+
+// validate makes the request conditional, so the service can answer with
+// 304 Not Modified instead of sending the body again
+func (e cacheEntry) validate(req *http.Request) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	if modified := e.Header.Get("Last-Modified"); modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+}
+
+// refresh updates the entry with the headers of a 304 response, which
+// restarts its max-age
+func (e *cacheEntry) refresh(header http.Header, now time.Time) {
+	for key, values := range header {
+		if key == "Content-Length" {
+			continue
+		}
+		e.Header[key] = values
+	}
+	e.Stored = now
+}
+
+// response builds a http response from the cached entry
+func (e cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        e.Status,
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheControl parses the Cache-Control directives, directives without a
+// value map to an empty string
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func noStore(header http.Header) bool {
+	_, ok := cacheControl(header)["no-store"]
+	return ok
+}
+
+func loadCacheEntry(service, key string) (*cacheEntry, error) {
+	var entry *cacheEntry
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+
+		sb := b.Bucket([]byte(service))
+		if sb == nil {
+			return nil
+		}
+
+		data := sb.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		entry = &cacheEntry{}
+		return json.Unmarshal(data, entry)
+	})
+
+	return entry, err
+}
+
+func storeCacheEntry(service, key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(cacheBucket))
+		if err != nil {
+			return err
+		}
+
+		sb, err := b.CreateBucketIfNotExists([]byte(service))
+		if err != nil {
+			return err
+		}
+
+		return sb.Put([]byte(key), data)
+	})
+}
+
+func deleteCacheEntry(service, key string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(cacheBucket))
+		if b == nil {
+			return nil
+		}
+
+		sb := b.Bucket([]byte(service))
+		if sb == nil {
+			return nil
+		}
+
+		return sb.Delete([]byte(key))
+	})
+}
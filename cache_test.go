@@ -0,0 +1,225 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	defer testDB(t, "cached")()
+
+	sent := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		sent[req.URL.Path]++
+		fmt.Fprint(w, req.URL.Path)
+	}))
+	defer server.Close()
+
+	get := func(r Request, path string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r.send(req)
+	}
+
+	r := Request{Service: "cached", Settings: NewSettings()}
+	r.Settings.Cache = sql.NullBool{Bool: true, Valid: true}
+
+	for _, path := range []string{"/etag", "/fresh", "/no-store"} {
+		for i := 0; i < 3; i++ {
+			resp, err := get(r, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || string(body) != path {
+				t.Errorf("%s: expected 200 %s got %d %s", path, path, resp.StatusCode, body)
+			}
+		}
+	}
+
+	// revalidated responses are only sent once, fresh responses aren't requested again
+	expected := map[string]int{"/etag": 1, "/fresh": 1, "/no-store": 3}
+	for path, count := range expected {
+		if sent[path] != count {
+			t.Errorf("%s: expected the body to be sent %d times got %d", path, count, sent[path])
+		}
+	}
+
+	// offline requests never reach the server
+	r.Settings.Offline = sql.NullBool{Bool: true, Valid: true}
+	server.Close()
+
+	resp, err := get(r, "/etag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "/etag" {
+		t.Errorf("expected cached /etag got %s", body)
+	}
+
+	if _, err := get(r, "/no-store"); err == nil {
+		t.Error("expected an error for a response that wasn't cached")
+	}
+}
+
+func TestCacheIdentity(t *testing.T) {
+	defer testDB(t, "cached")()
+
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sent++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		fmt.Fprint(w, req.Header.Get("Authorization"), " ", req.Header.Get("Accept"))
+	}))
+	defer server.Close()
+
+	get := func(token, accept string) string {
+		r := Request{Service: "cached", Settings: NewSettings()}
+		r.Settings.Cache = sql.NullBool{Bool: true, Valid: true}
+		r.Settings.AuthToken = sql.NullString{String: token, Valid: true}
+		r.credentials = r.Settings.credentials(func(s string) string { return s })
+
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", accept)
+
+		resp, err := r.send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	tests := []struct {
+		token, accept, expected string
+		sent                    int
+	}{
+		{"alice", "text/plain", "Bearer alice text/plain", 1},
+		{"alice", "text/plain", "Bearer alice text/plain", 1},
+		// another identity is never served the cached response
+		{"bob", "text/plain", "Bearer bob text/plain", 2},
+		// nor is a request the response varies from
+		{"alice", "application/json", "Bearer alice application/json", 3},
+	}
+
+	for i, test := range tests {
+		if body := get(test.token, test.accept); body != test.expected {
+			t.Errorf("%d: expected %s got %s", i, test.expected, body)
+		}
+		if sent != test.sent {
+			t.Errorf("%d: expected %d requests sent got %d", i, test.sent, sent)
+		}
+	}
+}
+
+func TestCacheSkipsOutputFile(t *testing.T) {
+	defer testDB(t, "cached")()
+
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sent++
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, "download")
+	}))
+	defer server.Close()
+
+	r := Request{Service: "cached", Settings: NewSettings()}
+	r.Settings.Cache = sql.NullBool{Bool: true, Valid: true}
+	r.Settings.OutputFile = sql.NullString{String: "download.txt", Valid: true}
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := r.send(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if sent != 2 {
+		t.Errorf("expected downloads to bypass the cache, sent %d requests", sent)
+	}
+}
+
+func TestCacheOfflineAPIKeyInQuery(t *testing.T) {
+	defer testDB(t, "cached")()
+
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sent++
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, req.URL.Query().Get("q"))
+	}))
+	defer server.Close()
+
+	r := Request{Service: "cached", Settings: NewSettings()}
+	r.Settings.Cache = sql.NullBool{Bool: true, Valid: true}
+	r.Settings.AuthType = sql.NullString{String: "api-key", Valid: true}
+	r.Settings.AuthKeyName = sql.NullString{String: "appid", Valid: true}
+	r.Settings.AuthKey = sql.NullString{String: "secret", Valid: true}
+	r.Settings.AuthKeyIn = sql.NullString{String: "query", Valid: true}
+	r.credentials = r.Settings.credentials(func(s string) string { return s })
+
+	for _, offline := range []bool{false, true} {
+		r.Settings.Offline = sql.NullBool{Bool: offline, Valid: true}
+
+		req, err := http.NewRequest("GET", server.URL+"/search?q=rest&page=1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// offline requests aren't authenticated
+		if !offline {
+			if err := (apiKeyAuth{name: "appid", key: "secret", query: true}).Apply(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp, err := r.send(req)
+		if err != nil {
+			t.Fatalf("offline %t: %s", offline, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "rest" {
+			t.Errorf("offline %t: unexpected body %s", offline, body)
+		}
+	}
+
+	if sent != 1 {
+		t.Errorf("expected the offline request to use the cache, sent %d requests", sent)
+	}
+}
@@ -14,6 +14,9 @@ var (
 	lstSrv  = srv.Command("list", "list all stored services")
 	config  = srv.Command("config", "show and alter service configs")
 	action  = srv.Command("alias", "set an action")
+	login   = srv.Command("login", "log in to the service using the oauth2 authorization code flow, the tokens are stored with the service")
+	impSrv  = srv.Command("import", "create or update a service and its aliases from a postman collection, settings already stored are kept")
+	expSrv  = srv.Command("export", "export the current service and its aliases, written to stdout")
 
 	get    = kingpin.Command("get", "Perform a GET request")
 	post   = kingpin.Command("post", "Perform a POST request")
@@ -22,6 +25,22 @@ var (
 	delete = kingpin.Command("delete", "Perform a DELETE request")
 	option = kingpin.Command("options", "Perform an OPTIONS request")
 	head   = kingpin.Command("head", "Perform a HEAD request")
+
+	ws = kingpin.Command("ws", "Open a WebSocket session, messages are read from stdin and received frames are written to stdout")
+
+	batch = kingpin.Command("batch", "Run an alias for every row of a CSV or JSON Lines file, each row fills the alias parameters")
+
+	benchmark = kingpin.Command("bench", "Load test an alias or request, and report the throughput and latency")
+
+	hist          = kingpin.Command("history", "commands to look at and replay the requests that have been performed")
+	historyList   = hist.Command("list", "list the most recent requests")
+	historyShow   = hist.Command("show", "show the request and response of a history entry")
+	historyReplay = hist.Command("replay", "perform a request from the history again, against the current service or the one given with --service")
+	historyClear  = hist.Command("clear", "remove every request from the history")
+
+	compare      = kingpin.Command("diff", "compare two responses, the exit code is 2 when they differ")
+	diffHistory  = compare.Command("history", "compare the responses of two history entries")
+	diffServices = compare.Command("alias", "run an alias against two services and compare the responses")
 )
 
 func init() {
@@ -32,8 +51,11 @@ func init() {
 	requestMethod(delete)
 	requestMethod(option)
 	requestMethod(head)
+	wsMethod(ws)
 }
 
+const dataUsage = "data to send in the request, use @file to read it from a file, or - to read it from stdin"
+
 // requestFlags apply to all the basic request types
 func requestFlags(cmd *kingpin.CmdClause, hide bool) {
 	nh := cmd.Flag("no-headers", "ignore stored service headers")
@@ -54,6 +76,12 @@ func requestFlags(cmd *kingpin.CmdClause, hide bool) {
 	}
 	dryRun.BoolVar(&request.DryRun)
 
+	as := cmd.Flag("as", "print the request as a curl command instead of performing it")
+	if hide {
+		as.Hidden()
+	}
+	as.EnumVar(&request.As, "curl")
+
 	settings = NewSettings()
 	settings.Flags(cmd, hide)
 }
@@ -67,7 +95,18 @@ func requestMethod(cmd *kingpin.CmdClause) {
 // requestDataMethod applies to all request that accept a body
 func requestDataMethod(cmd *kingpin.CmdClause) {
 	cmd.Arg("path", "url to perform request on").Required().StringVar(&request.Path)
-	cmd.Arg("data", "data to send in the request").Required().StringVar(&request.Data)
+	cmd.Arg("data", dataUsage).StringVar(&request.Data)
+	cmd.Flag("edit", "edit the request data in $EDITOR before sending it").BoolVar(&request.Edit)
+
+	requestFlags(cmd, false)
+	settings.FormFlags(cmd, false)
+}
+
+// wsMethod applies to websocket sessions
+func wsMethod(cmd *kingpin.CmdClause) {
+	cmd.Arg("path", "url to open the websocket on").Required().StringVar(&request.Path)
+	cmd.Arg("message", "message to send once connected, use @file to read it from a file").StringVar(&request.Data)
+	wsFlags(cmd)
 
 	requestFlags(cmd, false)
 }
@@ -2,6 +2,10 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
 
 	"github.com/boltdb/bolt"
 )
@@ -9,6 +13,18 @@ import (
 var (
 	configKey   string
 	configValue *string
+
+	// secretSettings are never displayed, they are keyed by the bucket they
+	// are stored in and then the setting name
+	secretSettings = map[string]map[string]bool{
+		"":        {"password": true},
+		"auth":    {"token": true, "key": true, "client-secret": true, "refresh-token": true},
+		"oauth2":  {"access-token": true, "refresh-token": true},
+		"signing": {"secret": true, "session-token": true},
+	}
+
+	// reference matches a value that is only a parameter or environment variable
+	reference = regexp.MustCompile(`^(:[[:word:]]+|{{[[:word:]-]+}}|\$[[:word:]]+|\$\{[[:word:]]+\})$`)
 )
 
 func init() {
@@ -23,7 +39,7 @@ func displayConfig() error {
 		}
 
 		if configKey != "" {
-			displayServiceKey(b, request.Service, configKey)
+			displayServiceKey(os.Stdout, b, request.Service, configKey)
 		} else {
 			fmt.Println(request.Service)
 			printBucket(b, 1)
@@ -33,13 +49,54 @@ func displayConfig() error {
 	})
 }
 
-func displayServiceKey(b *bolt.Bucket, service, key string) {
+func displayServiceKey(w io.Writer, b *bolt.Bucket, service, key string) {
 	if b == nil {
 		return
 	}
 
 	if asBucket := getBucketFromBucket(b, key); asBucket != nil {
-		printBucket(asBucket, 0)
+		// secrets are redacted by the name of the bucket they are stored in
+		name := ""
+		for _, p := range strings.Split(key, ".") {
+			name = secretBucket(name, p)
+		}
+		printNestedBucket(w, asBucket, name, 0)
 		return
 	}
 }
+
+// secretBucket is the name secrets are redacted by for a bucket nested in
+// parent, buckets without secrets of their own, such as the cached oauth2
+// tokens of each client, keep the secrets of their parent
+func secretBucket(parent, name string) string {
+	if _, ok := secretSettings[name]; ok {
+		return name
+	}
+
+	if _, ok := secretSettings[parent]; ok {
+		return parent
+	}
+
+	return name
+}
+
+// redactSetting hides secret values.  Values that are only a reference to a
+// parameter or environment variable are shown as they don't contain the secret.
+func redactSetting(bucket, key string, value []byte) []byte {
+	secrets, ok := secretSettings[bucket]
+	if !ok {
+		// top level settings are also stored in alias, path, and method buckets
+		secrets = secretSettings[""]
+	}
+
+	if !secrets[key] {
+		return value
+	}
+
+	v := string(value)
+	if v == "" || reference.MatchString(v) {
+		return value
+	}
+
+	return []byte("<redacted>")
+}
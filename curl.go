@@ -0,0 +1,544 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// ErrCurl is returned when the curl command can't be read or written
+type ErrCurl struct {
+	Reason string
+}
+
+func (e ErrCurl) Error() string {
+	return fmt.Sprintf("curl command: %s", e.Reason)
+}
+
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes the word for a POSIX shell
+func shellQuote(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// curlCommand returns a curl command line that sends the same request,
+// including the transport settings curl supports
+func (r *Request) curlCommand(req *http.Request) (string, error) {
+	args := [][]string{{"curl"}}
+	add := func(arg ...string) {
+		args = append(args, arg)
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		add("--head")
+	default:
+		add("-X", req.Method)
+	}
+
+	// curl writes the form itself, so the content type with the boundary is left out
+	form := r.hasForm()
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		if form && key == "Content-Type" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range req.Header[key] {
+			add("-H", key+": "+value)
+		}
+	}
+
+	switch {
+	case form && len(r.Files) == 0:
+		for _, key := range sortedKeys(r.Form) {
+			add("--data-urlencode", key+"="+r.Form[key])
+		}
+	case form:
+		for _, key := range sortedKeys(r.Form) {
+			add("--form-string", key+"="+r.Form[key])
+		}
+		for _, key := range sortedKeys(r.Files) {
+			file, err := homedir.Expand(os.ExpandEnv(r.Files[key]))
+			if err != nil {
+				return "", err
+			}
+			add("-F", key+"=@"+file)
+		}
+	default:
+		body, err := requestBody(req)
+		if err != nil {
+			return "", err
+		}
+		if len(body) > 0 {
+			if !utf8.Valid(body) || strings.ContainsRune(string(body), 0) {
+				return "", ErrCurl{Reason: "the binary body can't be written on the command line"}
+			}
+			// unlike --data-binary a leading @ isn't read as a file
+			add("--data-raw", string(body))
+		}
+	}
+
+	s := r.Settings
+	if socket := s.socket(); socket != "" {
+		add("--unix-socket", socket)
+	}
+	if s.Insecure.Bool {
+		add("--insecure")
+	}
+	replace := replacer(s.Parameters)
+	for _, option := range []struct {
+		flag  string
+		value sql.NullString
+	}{
+		{"--cacert", s.CAFile},
+		{"--cert", s.ClientCert},
+		{"--key", s.ClientKey},
+		{"--proxy", s.Proxy},
+		{"--noproxy", s.NoProxy},
+	} {
+		if option.value.Valid && option.value.String != "" {
+			add(option.flag, replace(option.value.String))
+		}
+	}
+	if s.ConnectTimeout.Valid && s.ConnectTimeout != defaultSettings.ConnectTimeout {
+		add("--connect-timeout", fmt.Sprint(s.ConnectTimeout.Duration.Seconds()))
+	}
+	if s.Timeout.Valid && s.Timeout.Duration > 0 {
+		add("--max-time", fmt.Sprint(s.Timeout.Duration.Seconds()))
+	}
+
+	add(req.URL.String())
+
+	lines := make([]string, len(args))
+	for i, arg := range args {
+		words := make([]string, len(arg))
+		for j, word := range arg {
+			words[j] = shellQuote(word)
+		}
+		lines[i] = strings.Join(words, " ")
+	}
+
+	return strings.Join(lines, " \\\n  "), nil
+}
+
+// splitShell splits the command into words the way a POSIX shell does,
+// handling quotes, escapes, and line continuations
+func splitShell(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	escaped := false
+
+	for _, c := range command {
+		switch {
+		case escaped:
+			escaped = false
+			// an escaped newline continues the line
+			if c != '\n' {
+				word.WriteRune(c)
+				inWord = true
+			}
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case quote == '"':
+			switch c {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, ErrCurl{Reason: "unterminated quote"}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// curlRequest is what a curl command sends
+type curlRequest struct {
+	Method   string
+	URL      *url.URL
+	Header   http.Header
+	Data     []string
+	Form     map[string]string
+	Files    map[string]string
+	Username string
+	Password string
+}
+
+// curl options that take a value, the ones that aren't used are skipped
+var curlValueOptions = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-raw": true, "--data-binary": true, "--data-ascii": true, "--data-urlencode": true, "--json": true,
+	"-F": true, "--form": true, "--form-string": true,
+	"-u": true, "--user": true, "-A": true, "--user-agent": true, "-e": true, "--referer": true, "-b": true, "--cookie": true,
+	"--url": true, "-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"-x": true, "--proxy": true, "--cacert": true, "-E": true, "--cert": true, "--key": true, "--unix-socket": true,
+	"-w": true, "--write-out": true, "-c": true, "--cookie-jar": true, "--retry": true,
+	"-D": true, "--dump-header": true, "--max-redirs": true, "--retry-delay": true, "--retry-max-time": true,
+	"-T": true, "--upload-file": true, "--resolve": true, "--oauth2-bearer": true, "-K": true, "--config": true,
+}
+
+// expandShortOptions splits combined short options, eg. -sSH into -s -S -H.
+// Everything after an option that takes a value is the value, so -XPOST is
+// -X POST.
+func expandShortOptions(word string) []string {
+	var options []string
+	for i := 1; i < len(word); i++ {
+		option := "-" + word[i:i+1]
+		options = append(options, option)
+		if curlValueOptions[option] {
+			if i+1 < len(word) {
+				options = append(options, word[i+1:])
+			}
+			break
+		}
+	}
+	return options
+}
+
+// parseCurl reads the request from a curl command line
+func parseCurl(command string) (curlRequest, error) {
+	words, err := splitShell(command)
+	if err != nil {
+		return curlRequest{}, err
+	}
+
+	if len(words) > 0 && words[0] == "curl" {
+		words = words[1:]
+	}
+
+	c := curlRequest{
+		Header: make(http.Header),
+		Form:   make(map[string]string),
+		Files:  make(map[string]string),
+	}
+	get := false
+	rawURL := ""
+
+	setURL := func(u string) error {
+		if rawURL != "" {
+			return ErrCurl{Reason: fmt.Sprintf("more than one url, %s and %s", rawURL, u)}
+		}
+		rawURL = u
+		return nil
+	}
+
+	for i := 0; i < len(words); i++ {
+		if !strings.HasPrefix(words[i], "--") && len(words[i]) > 2 && strings.HasPrefix(words[i], "-") {
+			expanded := expandShortOptions(words[i])
+			words = append(words[:i:i], append(expanded, words[i+1:]...)...)
+		}
+		option, value := words[i], ""
+
+		if !strings.HasPrefix(option, "-") || option == "-" {
+			if err := setURL(option); err != nil {
+				return c, err
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(option, "--") && strings.Contains(option, "="):
+			option, value, _ = strings.Cut(option, "=")
+		case curlValueOptions[option]:
+			i++
+			if i >= len(words) {
+				return c, ErrCurl{Reason: option + " is missing its value"}
+			}
+			value = words[i]
+		}
+
+		switch option {
+		case "-X", "--request":
+			c.Method = strings.ToUpper(value)
+		case "-I", "--head":
+			c.Method = http.MethodHead
+		case "-G", "--get":
+			get = true
+		case "-H", "--header":
+			key, v, found := strings.Cut(value, ":")
+			if !found {
+				return c, ErrCurl{Reason: "header " + value + " has no value"}
+			}
+			c.Header.Add(strings.TrimSpace(key), strings.TrimSpace(v))
+		case "-A", "--user-agent":
+			c.Header.Set("User-Agent", value)
+		case "-e", "--referer":
+			c.Header.Set("Referer", value)
+		case "-b", "--cookie":
+			c.Header.Add("Cookie", value)
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			c.Data = append(c.Data, value)
+		case "--data-raw":
+			// the data isn't a file reference, so escape a leading @
+			if strings.HasPrefix(value, "@") {
+				value = "@" + value
+			}
+			c.Data = append(c.Data, value)
+		case "--json":
+			c.Data = append(c.Data, value)
+			c.Header.Set("Content-Type", "application/json")
+			c.Header.Set("Accept", "application/json")
+		case "--data-urlencode":
+			key, v, found := strings.Cut(value, "=")
+			if !found {
+				key, v = "", key
+			}
+			c.Form[key] = v
+		case "-F", "--form":
+			key, v, _ := strings.Cut(value, "=")
+			if strings.HasPrefix(v, "@") {
+				// curl allows ;type= after the file name
+				file, _, _ := strings.Cut(v[1:], ";")
+				c.Files[key] = file
+				break
+			}
+			c.Form[key] = v
+		case "--form-string":
+			key, v, _ := strings.Cut(value, "=")
+			c.Form[key] = v
+		case "--oauth2-bearer":
+			c.Header.Set("Authorization", "Bearer "+value)
+		case "-u", "--user":
+			c.Username, c.Password, _ = strings.Cut(value, ":")
+		case "--url":
+			if err := setURL(value); err != nil {
+				return c, err
+			}
+		default:
+			if value == "" {
+				log.Printf("ignoring curl option %s\n", option)
+			} else {
+				log.Printf("ignoring curl option %s %s\n", option, value)
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return c, ErrCurl{Reason: "no url"}
+	}
+
+	// curl assumes http when there is no scheme
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	c.URL, err = url.Parse(rawURL)
+	if err != nil {
+		return c, err
+	}
+
+	// -G sends the data as the query
+	if get {
+		q := c.URL.Query()
+		for _, data := range c.Data {
+			values, err := url.ParseQuery(data)
+			if err != nil {
+				return c, err
+			}
+			for key, value := range values {
+				q[key] = append(q[key], value...)
+			}
+		}
+		c.URL.RawQuery = q.Encode()
+		c.Data = nil
+		if c.Method == "" {
+			c.Method = http.MethodGet
+		}
+	}
+
+	if c.Method == "" {
+		c.Method = http.MethodGet
+		if len(c.Data) > 0 || len(c.Form) > 0 || len(c.Files) > 0 {
+			c.Method = http.MethodPost
+		}
+	}
+
+	return c, nil
+}
+
+// placeholders turns the values of parameters into :param placeholders.
+// Path segments and query values must match the whole value, headers and
+// data match anywhere, but only values long enough to not match by accident.
+type placeholders struct {
+	names  []string
+	values map[string]string
+}
+
+func newPlaceholders(params map[string]string) placeholders {
+	p := placeholders{values: make(map[string]string)}
+	for name, value := range params {
+		// a parameter set to another parameter is not a value
+		if value == "" || strings.HasPrefix(value, ":") {
+			continue
+		}
+		p.names = append(p.names, name)
+		p.values[name] = value
+	}
+
+	// replace the longest values first, so a value inside another isn't replaced
+	sort.Slice(p.names, func(i, j int) bool {
+		a, b := p.values[p.names[i]], p.values[p.names[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return p.names[i] < p.names[j]
+	})
+
+	return p
+}
+
+// whole replaces the value if it matches a parameter exactly
+func (p placeholders) whole(value string) string {
+	for _, name := range p.names {
+		if p.values[name] == value {
+			return ":" + name
+		}
+	}
+	return value
+}
+
+// within replaces parameter values found anywhere in the value
+func (p placeholders) within(value string) string {
+	for _, name := range p.names {
+		if len(p.values[name]) >= 4 {
+			value = strings.Replace(value, p.values[name], ":"+name, -1)
+		}
+	}
+	return value
+}
+
+// fromCurl fills in the alias being added from the curl command.  The path
+// is made relative to the service base path, and anything set on the
+// command line is kept.
+func fromCurl(command string, service Settings) error {
+	c, err := parseCurl(command)
+	if err != nil {
+		return err
+	}
+
+	params := make(map[string]string)
+	for key, value := range service.Parameters {
+		params[key] = value
+	}
+	for key, value := range settings.Parameters {
+		params[key] = value
+	}
+	p := newPlaceholders(params)
+
+	if host := service.Host.String; host != "" && c.URL.Hostname() != host {
+		log.Printf("the curl url host %s isn't the service host %s\n", c.URL.Hostname(), host)
+	}
+
+	path := strings.TrimPrefix(c.URL.Path, "/")
+	if base := strings.Trim(service.BasePath.String, "/"); base != "" {
+		if path == base || strings.HasPrefix(path, base+"/") {
+			path = strings.TrimPrefix(path[len(base):], "/")
+		} else {
+			log.Printf("the curl url path /%s isn't under the service base path /%s\n", path, base)
+		}
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = p.whole(segment)
+	}
+
+	if request.Method == "" {
+		request.Method = strings.ToLower(c.Method)
+	}
+	if request.Path == "" {
+		request.Path = strings.Join(segments, "/")
+	}
+	if request.Data == "" && len(c.Data) > 0 {
+		data := strings.Join(c.Data, "&")
+		// a file reference is kept as is
+		if !strings.HasPrefix(data, "@") || strings.HasPrefix(data, "@@") {
+			data = p.within(data)
+		}
+		request.Data = data
+	}
+
+	fill := func(m map[string]string, key, value string) {
+		if _, ok := m[key]; !ok {
+			m[key] = value
+		}
+	}
+
+	for key, values := range c.URL.Query() {
+		fill(settings.Queries, key, p.whole(values[0]))
+	}
+	for key, values := range c.Header {
+		fill(settings.Headers, key, p.within(strings.Join(values, ", ")))
+	}
+	for key, value := range c.Form {
+		fill(settings.Form, key, p.within(value))
+	}
+	for key, value := range c.Files {
+		fill(settings.Files, key, value)
+	}
+
+	if c.Username != "" && !settings.Username.Valid {
+		settings.Username = sql.NullString{String: p.whole(c.Username), Valid: true}
+	}
+	if c.Password != "" && !settings.Password.Valid {
+		settings.Password = sql.NullString{String: p.whole(c.Password), Valid: true}
+	}
+
+	// curl asks for a missing password, without one the credentials would
+	// be ignored, so use basic auth to send them anyway
+	if c.Username != "" && c.Password == "" && !settings.AuthType.Valid {
+		settings.AuthType = sql.NullString{String: "basic", Valid: true}
+		log.Printf("the curl command has no password for %s, set it with --password\n", c.Username)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/a?b=c": "'https://example.com/a?b=c'",
+		"-X":                        "-X",
+		"it's":                      `'it'\''s'`,
+		"a b":                       "'a b'",
+	}
+
+	for word, expected := range tests {
+		quoted := shellQuote(word)
+		if quoted != expected {
+			t.Errorf("%s: expected %s got %s", word, expected, quoted)
+		}
+
+		split, err := splitShell(quoted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(split) != 1 || split[0] != word {
+			t.Errorf("%s: quoting did not round trip %q", word, split)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	c, err := parseCurl(`curl -sS -XPUT "https://example.com/v1/users/42?x=1" \
+  -H 'Accept: application/json' -H "X-Quote: \"q\"" \
+  --data-raw '@not-a-file' -u user:pass --compressed`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Method != "PUT" || c.URL.Path != "/v1/users/42" || c.URL.Query().Get("x") != "1" {
+		t.Errorf("unexpected request %s %s", c.Method, c.URL)
+	}
+	if c.Header.Get("Accept") != "application/json" || c.Header.Get("X-Quote") != `"q"` {
+		t.Errorf("unexpected headers %v", c.Header)
+	}
+	if len(c.Data) != 1 || c.Data[0] != "@@not-a-file" {
+		t.Errorf("unexpected data %q", c.Data)
+	}
+	if c.Username != "user" || c.Password != "pass" {
+		t.Errorf("unexpected user %s:%s", c.Username, c.Password)
+	}
+
+	c, err = parseCurl(`curl example.com/search -G -d q=rest -d page=2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Method != "GET" || c.URL.Query().Get("q") != "rest" || c.URL.Query().Get("page") != "2" || len(c.Data) != 0 {
+		t.Errorf("expected -G to move the data to the query, got %s %s %q", c.Method, c.URL, c.Data)
+	}
+
+	c, err = parseCurl(`curl https://example.com/upload -F name=report -F file=@report.pdf;type=application/pdf`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Method != "POST" || c.Form["name"] != "report" || c.Files["file"] != "report.pdf" {
+		t.Errorf("unexpected form %s %v %v", c.Method, c.Form, c.Files)
+	}
+
+	if _, err := parseCurl(`curl -H`); err == nil {
+		t.Error("expected an error for a missing value")
+	}
+
+	// options that take a value don't replace the url
+	for _, option := range []string{
+		"-D -", "--dump-header headers.txt", "--max-redirs 5", "--retry-delay 2", "--retry-max-time 30",
+		"-T upload.json", "--resolve api.example.com:443:127.0.0.1", "-K curlrc",
+	} {
+		c, err = parseCurl(`curl https://api.example.com/users ` + option)
+		if err != nil {
+			t.Fatalf("%s: %s", option, err)
+		}
+		if c.URL.String() != "https://api.example.com/users" {
+			t.Errorf("%s: unexpected url %s", option, c.URL)
+		}
+	}
+
+	c, err = parseCurl(`curl --oauth2-bearer abc123 https://api.example.com/users`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Get("Authorization") != "Bearer abc123" || c.URL.Host != "api.example.com" {
+		t.Errorf("unexpected bearer request %s %v", c.URL, c.Header)
+	}
+
+	// combined short options are split, and the last may take a value
+	c, err = parseCurl(`curl -sH 'X-A: b' -sSXPOST https://api.example.com/users`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Get("X-A") != "b" || c.Method != "POST" || c.URL.Host != "api.example.com" {
+		t.Errorf("unexpected combined options %s %s %v", c.Method, c.URL, c.Header)
+	}
+
+	for _, command := range []string{
+		`curl https://api.example.com/users https://api.example.com/pets`,
+		`curl https://api.example.com/users --url https://api.example.com/pets`,
+	} {
+		if _, err := parseCurl(command); err == nil {
+			t.Errorf("%s: expected an error for a second url", command)
+		}
+	}
+}
+
+func TestCurlRoundTrip(t *testing.T) {
+	r := Request{Settings: NewSettings()}
+	req, err := http.NewRequest("POST", "https://example.com/v1/users?q=a+b", strings.NewReader(`{"name": "it's"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	command, err := r.curlCommand(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := parseCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Method != "POST" || c.URL.String() != req.URL.String() {
+		t.Errorf("unexpected request %s %s", c.Method, c.URL)
+	}
+	if c.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers %v", c.Header)
+	}
+	if len(c.Data) != 1 || c.Data[0] != `{"name": "it's"}` {
+		t.Errorf("unexpected data %q", c.Data)
+	}
+}
+
+func TestCurlDataRaw(t *testing.T) {
+	r := Request{Settings: NewSettings()}
+	req, err := http.NewRequest("POST", "https://example.com/messages", strings.NewReader("@alice hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	command, err := r.curlCommand(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(command, "--data-raw '@alice hello'") {
+		t.Errorf("expected the body not to be read as a file %s", command)
+	}
+
+	c, err := parseCurl(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the data is escaped so rest doesn't read it as a file either
+	if len(c.Data) != 1 || c.Data[0] != "@@alice hello" {
+		t.Errorf("unexpected data %q", c.Data)
+	}
+}
+
+func TestFromCurl(t *testing.T) {
+	defer func() {
+		request = Request{}
+		settings = NewSettings()
+	}()
+	request = Request{}
+	settings = NewSettings()
+
+	service := NewSettings()
+	service.BasePath = sql.NullString{String: "/v1", Valid: true}
+	service.Parameters["id"] = "4242"
+	service.Parameters["token"] = "abc123secret"
+	service.Parameters["short"] = "1"
+
+	err := fromCurl(`curl -X PATCH 'https://api.example.com/v1/users/4242?id=4242&page=1' -H 'Authorization: Bearer abc123secret' -d '{"id": 4242, "n": 1}'`, service)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.Method != "patch" || request.Path != "users/:id" {
+		t.Errorf("unexpected alias %s %s", request.Method, request.Path)
+	}
+	if request.Data != `{"id": :id, "n": 1}` {
+		t.Errorf("unexpected data %s", request.Data)
+	}
+	if settings.Queries["id"] != ":id" || settings.Queries["page"] != ":short" {
+		t.Errorf("unexpected queries %v", settings.Queries)
+	}
+	if settings.Headers["Authorization"] != "Bearer :token" {
+		t.Errorf("unexpected headers %v", settings.Headers)
+	}
+
+	// a user without a password is still sent
+	request = Request{}
+	settings = NewSettings()
+	if err := fromCurl(`curl -u alice https://api.example.com/v1/me`, service); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Username.String != "alice" || settings.AuthType.String != "basic" {
+		t.Errorf("expected basic auth for alice got %s %s", settings.AuthType.String, settings.Username.String)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+var ErrEmptyData = errors.New("aborting request due to empty request data")
+
+// readData resolves a data reference, '-' reads from stdin, '@file' reads the
+// file, and '@@' escapes a literal leading '@'.  Anything else is used as is.
+func readData(data string) (string, error) {
+	switch {
+	case data == "-":
+		buf, err := ioutil.ReadAll(os.Stdin)
+		return string(buf), err
+
+	case strings.HasPrefix(data, "@@"):
+		return data[1:], nil
+
+	case strings.HasPrefix(data, "@"):
+		file, err := homedir.Expand(os.ExpandEnv(data[1:]))
+		if err != nil {
+			return "", err
+		}
+
+		buf, err := ioutil.ReadFile(file)
+		return string(buf), err
+	}
+
+	return data, nil
+}
+
+// LoadData replaces the data reference with the request data, and opens the
+// editor if requested.  This happens before the data hook and parameter
+// replacement so both still apply to data loaded from elsewhere.
+func (r *Request) LoadData() error {
+	data, err := readData(r.Data)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+
+	if !r.Edit {
+		return nil
+	}
+
+	r.Data, err = editData(r.Data)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(r.Data) == "" {
+		return ErrEmptyData
+	}
+
+	return nil
+}
+
+// editData opens the template in the users editor and returns the result
+func editData(template string) (string, error) {
+	f, err := ioutil.TempFile("", "rest-data-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(template); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// the editor may include arguments, eg. 'code --wait'
+	args := append(strings.Fields(editor), filepath.Clean(f.Name()))
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	buf, err := ioutil.ReadFile(f.Name())
+	return string(buf), err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestReadData(t *testing.T) {
+	f, err := ioutil.TempFile("", "rest-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`{"name": ":name"}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := map[string]string{
+		`{"inline": true}`: `{"inline": true}`,
+		"@" + f.Name():     `{"name": ":name"}`,
+		"@@handle":         "@handle",
+		"":                 "",
+	}
+
+	for data, expected := range tests {
+		result, err := readData(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Errorf("%s: expected %s got %s", data, expected, result)
+		}
+	}
+
+	if _, err := readData("@/does/not/exist"); err == nil {
+		t.Error("expected missing file to error")
+	}
+}
+
+func TestAliasData(t *testing.T) {
+	defer testDB(t, "alias")()
+
+	f, err := ioutil.TempFile("", "rest-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`{"name": ":name", "id": {{id}}}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		a, err := getBucket(tx, "services.alias").CreateBucket([]byte("add"))
+		if err != nil {
+			return err
+		}
+
+		// the alias already has settings stored in nested buckets
+		accept := "application/json"
+		if err := write(a, "headers.Accept", &accept); err != nil {
+			return err
+		}
+
+		// the file is referenced and its parameters are kept
+		if err := storeAliasData(a, "@"+f.Name()); err != nil {
+			return err
+		}
+		if file := string(a.Get([]byte("data-file"))); file != f.Name() {
+			t.Errorf("unexpected data file %s", file)
+		}
+		if params := string(a.Get([]byte("data-parameters"))); params != "id,name" {
+			t.Errorf("unexpected data parameters %s", params)
+		}
+		if data := storedAliasData(a); data != "@"+f.Name() {
+			t.Errorf("unexpected alias data %s", data)
+		}
+
+		// escaped data is stored as given and replaces the file
+		if err := storeAliasData(a, "@@handle"); err != nil {
+			return err
+		}
+		if data := string(a.Get([]byte("data"))); data != "@handle" {
+			t.Errorf("unexpected stored data %s", data)
+		}
+		if a.Get([]byte("data-file")) != nil || a.Get([]byte("data-parameters")) != nil {
+			t.Error("expected the file reference to be removed")
+		}
+
+		// stored data starting with @ is never read from a file
+		if err := a.Put([]byte("data"), []byte("@"+f.Name())); err != nil {
+			return err
+		}
+		data, err := readData(storedAliasData(a))
+		if err != nil {
+			return err
+		}
+		if data != "@"+f.Name() {
+			t.Errorf("expected literal data got %s", data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	jmespath "github.com/jmespath/go-jmespath"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	diffIDs           []uint64
+	diffAlias         string
+	diffServiceNames  []string
+	diffIgnore        []string
+	diffIgnoreHeaders []string
+)
+
+// these headers change on every response, so they are never compared
+var volatileHeaders = []string{"Date", "Age", "Expires", "Set-Cookie", "X-Request-Id"}
+
+func init() {
+	diffHistory.Arg("ids", "the two history entries to compare").Required().Uint64ListVar(&diffIDs)
+
+	diffServices.Arg("alias", "the alias to run").Required().StringVar(&diffAlias)
+	diffServices.Arg("services", "the two services to run the alias against").Required().StringsVar(&diffServiceNames)
+	requestFlags(diffServices, false)
+
+	for _, cmd := range []*kingpin.CmdClause{diffHistory, diffServices} {
+		cmd.Flag("ignore", "JMESPath selecting fields that always change, such as timestamps and ids, eg. 'updated_at' or 'items[*].id', can be repeated").
+			StringsVar(&diffIgnore)
+		cmd.Flag("ignore-header", "header to leave out of the comparison, as well as Date, Age, Expires, Set-Cookie, and X-Request-Id, can be repeated").
+			StringsVar(&diffIgnoreHeaders)
+	}
+}
+
+// ErrDiffArgs is returned when there aren't exactly two things to compare
+type ErrDiffArgs struct {
+	What  string
+	Count int
+}
+
+func (e ErrDiffArgs) Error() string {
+	return fmt.Sprintf("expected two %s to compare, got %d", e.What, e.Count)
+}
+
+// ErrDiffIgnore is returned when an ignore expression isn't valid JMESPath
+type ErrDiffIgnore struct {
+	Expression string
+	Err        error
+}
+
+func (e ErrDiffIgnore) Error() string {
+	return fmt.Sprintf("invalid ignore expression %s: %s", e.Expression, e.Err)
+}
+
+// diffResponse is the part of a response that is compared
+type diffResponse struct {
+	Name   string
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// difference is a single change between the two responses, the path is
+// a JMESPath to the changed value
+type difference struct {
+	Path string
+	Kind string
+	Old  interface{}
+	New  interface{}
+}
+
+func (d difference) String() string {
+	switch d.Kind {
+	case "added":
+		return fmt.Sprintf("%s: added %s", d.Path, diffValue(d.New))
+	case "removed":
+		return fmt.Sprintf("%s: removed %s", d.Path, diffValue(d.Old))
+	}
+	return fmt.Sprintf("%s: %s -> %s", d.Path, diffValue(d.Old), diffValue(d.New))
+}
+
+func diffValue(v interface{}) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+// DiffHistory compares two history entries, and returns if they differ
+func DiffHistory(w io.Writer) (bool, error) {
+	if len(diffIDs) != 2 {
+		return false, ErrDiffArgs{What: "history entries", Count: len(diffIDs)}
+	}
+
+	var responses [2]diffResponse
+	for i, id := range diffIDs {
+		entry, err := loadHistory(id)
+		if err != nil {
+			return false, err
+		}
+
+		if entry.ResponseBodyTruncated {
+			log.Printf("the response body of history entry %d wasn't saved in full\n", id)
+		}
+
+		responses[i] = diffResponse{
+			Name:   fmt.Sprintf("#%d", id),
+			Status: entry.Status,
+			Header: entry.ResponseHeader,
+			Body:   []byte(entry.ResponseBody),
+		}
+	}
+
+	return writeDiff(w, responses[0], responses[1], diffIgnore, diffIgnoreHeaders)
+}
+
+// DiffServices runs the alias against both services, and returns if the
+// responses differ
+func DiffServices(w io.Writer) (bool, error) {
+	if len(diffServiceNames) != 2 {
+		return false, ErrDiffArgs{What: "services", Count: len(diffServiceNames)}
+	}
+
+	var responses [2]diffResponse
+	for i, service := range diffServiceNames {
+		r := newRequest()
+		r.Service = service
+		if err := r.loadAlias(diffAlias); err != nil {
+			return false, err
+		}
+
+		var resp Response
+		if err := r.Execute(&resp); err != nil {
+			return false, err
+		}
+
+		responses[i] = diffResponse{
+			Name:   service,
+			Status: resp.resp.StatusCode,
+			Header: resp.resp.Header,
+			Body:   resp.Raw,
+		}
+	}
+
+	return writeDiff(w, responses[0], responses[1], diffIgnore, diffIgnoreHeaders)
+}
+
+// writeDiff writes the status, header, and body differences separately,
+// and returns if there were any
+func writeDiff(w io.Writer, a, b diffResponse, ignore, ignoreHeaders []string) (bool, error) {
+	differs := false
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", a.Name, b.Name)
+
+	if a.Status != b.Status {
+		differs = true
+		fmt.Fprintf(w, "status: %d -> %d\n", a.Status, b.Status)
+	}
+
+	for _, d := range diffHeaders(a.Header, b.Header, ignoreHeaders) {
+		differs = true
+		fmt.Fprintf(w, "header %s\n", d)
+	}
+
+	var docA, docB interface{}
+	errA := json.Unmarshal(a.Body, &docA)
+	errB := json.Unmarshal(b.Body, &docB)
+
+	// bodies that aren't json are compared line by line
+	if errA != nil || errB != nil {
+		lines := diffLines(string(a.Body), string(b.Body))
+		if len(lines) > 0 {
+			differs = true
+			fmt.Fprintln(w, "body:")
+			for _, line := range lines {
+				fmt.Fprintln(w, line)
+			}
+		}
+		return differs, nil
+	}
+
+	differences, err := diffJSON(docA, docB, ignore)
+	if err != nil {
+		return false, err
+	}
+
+	for _, d := range differences {
+		differs = true
+		fmt.Fprintf(w, "body %s\n", d)
+	}
+
+	return differs, nil
+}
+
+// diffHeaders compares the headers, the values of a header are compared together
+func diffHeaders(a, b http.Header, ignore []string) []difference {
+	skip := make(map[string]bool)
+	for _, name := range append(ignore, volatileHeaders...) {
+		skip[http.CanonicalHeaderKey(name)] = true
+	}
+
+	names := make(map[string]bool)
+	for name := range a {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+	for name := range b {
+		names[http.CanonicalHeaderKey(name)] = true
+	}
+
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		if !skip[name] {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	var differences []difference
+	for _, name := range keys {
+		old, new := a.Values(name), b.Values(name)
+		switch {
+		case len(old) == 0:
+			differences = append(differences, difference{Path: name, Kind: "added", New: strings.Join(new, ", ")})
+		case len(new) == 0:
+			differences = append(differences, difference{Path: name, Kind: "removed", Old: strings.Join(old, ", ")})
+		case strings.Join(old, ", ") != strings.Join(new, ", "):
+			differences = append(differences, difference{Path: name, Kind: "changed", Old: strings.Join(old, ", "), New: strings.Join(new, ", ")})
+		}
+	}
+
+	return differences
+}
+
+// diffJSON compares the documents structurally, so the order of keys
+// doesn't matter.  Values selected by the ignore expressions in either
+// document aren't compared.
+func diffJSON(a, b interface{}, ignore []string) ([]difference, error) {
+	ignored := make(map[string]bool)
+	for _, doc := range []interface{}{a, b} {
+		if err := ignoredPaths(doc, ignore, ignored); err != nil {
+			return nil, err
+		}
+	}
+
+	// a change is only ignored when every value it touches is ignored
+	skip := func(path string, values ...interface{}) bool {
+		if len(ignored) == 0 {
+			return false
+		}
+
+		all := true
+		for _, v := range values {
+			leaves(path, v, func(leaf string, _ interface{}) {
+				all = all && ignored[leaf]
+			})
+		}
+		return all
+	}
+
+	var differences []difference
+	var walk func(path string, a, b interface{})
+	walk = func(path string, a, b interface{}) {
+		switch av := a.(type) {
+		case map[string]interface{}:
+			bv, ok := b.(map[string]interface{})
+			if !ok {
+				break
+			}
+
+			keys := make([]string, 0, len(av)+len(bv))
+			for key := range av {
+				keys = append(keys, key)
+			}
+			for key := range bv {
+				if _, ok := av[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				child := fieldPath(path, key)
+				old, inA := av[key]
+				new, inB := bv[key]
+				switch {
+				case !inA && !skip(child, new):
+					differences = append(differences, difference{Path: child, Kind: "added", New: new})
+				case !inB && !skip(child, old):
+					differences = append(differences, difference{Path: child, Kind: "removed", Old: old})
+				case inA && inB:
+					walk(child, old, new)
+				}
+			}
+			return
+
+		case []interface{}:
+			bv, ok := b.([]interface{})
+			if !ok {
+				break
+			}
+
+			for i := 0; i < len(av) || i < len(bv); i++ {
+				child := indexPath(path, i)
+				switch {
+				case i >= len(av):
+					if !skip(child, bv[i]) {
+						differences = append(differences, difference{Path: child, Kind: "added", New: bv[i]})
+					}
+				case i >= len(bv):
+					if !skip(child, av[i]) {
+						differences = append(differences, difference{Path: child, Kind: "removed", Old: av[i]})
+					}
+				default:
+					walk(child, av[i], bv[i])
+				}
+			}
+			return
+		}
+
+		if !reflect.DeepEqual(a, b) && !skip(path, a, b) {
+			differences = append(differences, difference{Path: path, Kind: "changed", Old: a, New: b})
+		}
+	}
+	walk("@", a, b)
+
+	return differences, nil
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// fieldPath is the JMESPath of the key in the object at path, so paths
+// from the output can be used with --ignore
+func fieldPath(path, key string) string {
+	if !identifier.MatchString(key) {
+		key = strconv.Quote(key)
+	}
+	if path == "@" {
+		return key
+	}
+	return path + "." + key
+}
+
+func indexPath(path string, i int) string {
+	if path == "@" {
+		path = ""
+	}
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// leaves calls fn with the path of every value that isn't an object or
+// array in v, empty objects and arrays count as values
+func leaves(path string, v interface{}, fn func(path string, v interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for key, child := range v {
+			leaves(fieldPath(path, key), child, fn)
+		}
+		return
+	case []interface{}:
+		if len(v) == 0 {
+			break
+		}
+		for i, child := range v {
+			leaves(indexPath(path, i), child, fn)
+		}
+		return
+	}
+	fn(path, v)
+}
+
+// the values in the probe document are replaced with their path behind this
+// prefix, so the paths of the values an expression selects can be found
+const probePrefix = "\x00rest-diff:"
+
+// ignoredPaths adds the paths of the values selected by the expressions.
+// Expressions select by path, filters comparing values don't match as the
+// values are replaced.
+func ignoredPaths(doc interface{}, expressions []string, paths map[string]bool) error {
+	if len(expressions) == 0 {
+		return nil
+	}
+
+	var probe func(path string, v interface{}) interface{}
+	probe = func(path string, v interface{}) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			m := make(map[string]interface{}, len(v))
+			for key, child := range v {
+				m[key] = probe(fieldPath(path, key), child)
+			}
+			return m
+		case []interface{}:
+			s := make([]interface{}, len(v))
+			for i, child := range v {
+				s[i] = probe(indexPath(path, i), child)
+			}
+			return s
+		}
+		return probePrefix + path
+	}
+	probed := probe("@", doc)
+
+	for _, exp := range expressions {
+		found, err := jmespath.Search(exp, probed)
+		if err != nil {
+			return ErrDiffIgnore{Expression: exp, Err: err}
+		}
+
+		leaves("@", found, func(_ string, v interface{}) {
+			if s, ok := v.(string); ok && strings.HasPrefix(s, probePrefix) {
+				paths[strings.TrimPrefix(s, probePrefix)] = true
+			}
+		})
+	}
+
+	return nil
+}
+
+// diffLines is a line diff of the text, lines only in a start with - and
+// lines only in b start with +
+func diffLines(a, b string) []string {
+	if a == b {
+		return nil
+	}
+
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// the longest common subsequence table gets large quickly
+	if len(al)*len(bl) > 1000000 {
+		return []string{"text differs, too long to compare line by line"}
+	}
+
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			i++
+			j++
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+al[i])
+			i++
+		default:
+			lines = append(lines, "+ "+bl[j])
+			j++
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	var a, b interface{}
+	json.Unmarshal([]byte(`{"name": "one", "updated_at": 1, "items": [{"id": 1, "v": "a"}], "meta": {"x-y": 1}}`), &a)
+	json.Unmarshal([]byte(`{"items": [{"v": "a", "id": 2}, {"id": 3, "v": "b"}], "updated_at": 2, "meta": {"x-y": 2}, "name": "one"}`), &b)
+
+	differences, err := diffJSON(a, b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range differences {
+		got = append(got, d.String())
+	}
+	expected := []string{
+		`items[0].id: 1 -> 2`,
+		`items[1]: added {"id":3,"v":"b"}`,
+		`meta."x-y": 1 -> 2`,
+		`updated_at: 1 -> 2`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	// the added item still has a value that isn't ignored
+	differences, err = diffJSON(a, b, []string{"updated_at", "items[*].id", `meta."x-y"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(differences) != 1 || differences[0].Path != "items[1]" {
+		t.Errorf("expected only the added item got %v", differences)
+	}
+
+	if _, err := diffJSON(a, b, []string{"items[*"}); err == nil {
+		t.Error("expected an error for an invalid ignore expression")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	a := diffResponse{
+		Name:   "staging",
+		Status: 200,
+		Header: http.Header{"Date": {"yesterday"}, "Content-Type": {"application/json"}},
+		Body:   []byte(`{"id": 1, "tags": ["a", "b"]}`),
+	}
+	b := diffResponse{
+		Name:   "prod",
+		Status: 200,
+		Header: http.Header{"Date": {"today"}, "Content-Type": {"application/json"}},
+		Body:   []byte(`{"tags": ["a", "b"], "id": 1}`),
+	}
+
+	var out bytes.Buffer
+	differs, err := writeDiff(&out, a, b, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differs {
+		t.Errorf("expected no differences got\n%s", out.String())
+	}
+
+	b.Status = 500
+	b.Header.Set("Content-Type", "text/plain")
+	b.Body = []byte("internal\nerror")
+	a.Body = []byte("internal\nfailure")
+
+	out.Reset()
+	differs, err = writeDiff(&out, a, b, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `--- staging
++++ prod
+status: 200 -> 500
+header Content-Type: "application/json" -> "text/plain"
+body:
+- failure
++ error
+`
+	if !differs || out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// streamKind reports if the response is a stream of events that may never
+// finish, these are displayed as each event arrives
+func streamKind(resp *http.Response) string {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "text/event-stream":
+		return "sse"
+	case "application/x-ndjson", "application/ndjson", "application/jsonl",
+		"application/x-jsonlines", "application/json-seq":
+		return "ndjson"
+	}
+
+	return ""
+}
+
+// event is a single server-sent event
+type event struct {
+	id    string
+	name  string
+	data  []byte
+	retry time.Duration
+}
+
+// follow displays each event in the stream as it arrives, the hook, filter,
+// and set parameters are run on every event instead of the whole body.
+// Server-sent event streams are resumed after they end using the retry settings.
+func (r *Response) follow(kind string) error {
+	r.streamed = true
+
+	if kind == "ndjson" {
+		return r.followLines(r.resp.Body)
+	}
+
+	var lastID string
+	// retry is the reconnection delay sent by the server
+	var retry time.Duration
+	attempt := 0
+
+	for {
+		received, err := r.followEvents(r.resp.Body, &lastID, &retry)
+		r.resp.Body.Close()
+
+		if received {
+			attempt = 0
+		}
+
+		if r.reconnect == nil || attempt >= int(r.settings.Retries.Int64) {
+			return err
+		}
+
+		// without a delay from the server back off more on every attempt
+		delay := retry
+		if delay == 0 {
+			delay = r.settings.backoff(attempt)
+		}
+
+		if r.verbose > 0 {
+			if err != nil {
+				log.Println("event stream interrupted:", err)
+			}
+			log.Printf("reconnecting to event stream in %s, last event id %q\n", delay, lastID)
+		}
+
+		<-time.After(delay)
+		attempt++
+
+		resp, err := r.reconnect(lastID)
+		if err != nil {
+			return err
+		}
+
+		// the server tells us to stop reconnecting with a 204, any other
+		// response that isn't a stream is the result, which decides the
+		// exit code
+		r.resp = resp
+		if resp.StatusCode == http.StatusNoContent || streamKind(resp) != "sse" {
+			resp.Body.Close()
+			if r.verbose > 0 && resp.StatusCode != http.StatusNoContent {
+				log.Println("event stream ended, reconnecting returned", resp.Status)
+			}
+			return nil
+		}
+	}
+}
+
+// followLines treats each line as a separate json document
+func (r *Response) followLines(body io.Reader) error {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		// json text sequences start each record with a record separator
+		line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte{0x1e}))
+		if len(line) > 0 {
+			if perr := r.displayEvent(line); perr != nil {
+				return perr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// followEvents displays the events until the stream ends.  The last event id
+// and reconnection delay are kept up to date so the stream can be resumed.
+func (r *Response) followEvents(body io.Reader, lastID *string, retry *time.Duration) (bool, error) {
+	received := false
+	reader := bufio.NewReader(body)
+
+	var e event
+	var data [][]byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return received, err
+		}
+		// an incomplete event at the end of the stream is discarded
+		if err == io.EOF {
+			return received, nil
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+
+		// a blank line dispatches the event
+		if len(line) == 0 {
+			if e.id != "" {
+				*lastID = e.id
+			}
+			if data != nil {
+				e.data = bytes.Join(data, []byte("\n"))
+				received = true
+				if r.verbose > 1 {
+					log.Printf("event %q id %q\n", e.name, e.id)
+				}
+				if err := r.displayEvent(e.data); err != nil {
+					return received, err
+				}
+			}
+			e = event{}
+			data = nil
+			continue
+		}
+
+		// lines starting with a colon are comments used to keep the connection alive
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := parseEventField(line)
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "event":
+			e.name = string(value)
+		case "id":
+			// ids with null can't be sent back in a header
+			if !bytes.ContainsRune(value, 0) {
+				e.id = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(string(value)); err == nil {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func parseEventField(line []byte) (string, []byte) {
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return string(line), nil
+	}
+
+	return string(line[:i]), bytes.TrimPrefix(line[i+1:], []byte(" "))
+}
+
+// displayEvent runs the response processing on a single event and writes it out
+func (r *Response) displayEvent(data []byte) error {
+	// not every event is json, so only pretty print the ones that are
+	pretty := r.Pretty
+	defer func() { r.Pretty = pretty }()
+	r.Pretty = pretty && json.Valid(data)
+
+	r.Raw = data
+	if err := r.Prepare(); err != nil {
+		return err
+	}
+
+	// filters that don't match an event hide it
+	if r.Filter != "" && r.display == nil {
+		return nil
+	}
+
+	out := r.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	_, err := fmt.Fprintln(out, strings.TrimRight(string(r.display), "\n"))
+	return err
+}
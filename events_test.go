@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFollowEvents(t *testing.T) {
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		connections++
+		switch connections {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": keep alive\nretry: 1\n\nid: 1\ndata: {\"n\": 1}\n\nevent: update\nid: 2\ndata: {\"n\":\ndata: 2}\n\n")
+		case 2:
+			if id := req.Header.Get("Last-Event-ID"); id != "2" {
+				t.Errorf("expected to resume from event 2, got %q", id)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 3\ndata: {\"n\": 3}\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	connect := func(lastEventID string) (*http.Response, error) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	resp, err := connect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	r := Response{out: &out, reconnect: connect}
+	s := NewSettings()
+	s.Retries = sql.NullInt64{Int64: 1, Valid: true}
+	s.Filter = sql.NullString{String: "n", Valid: true}
+
+	if err := r.Load(resp, s); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "1\n2\n3\n" {
+		t.Errorf("unexpected events displayed %q", out.String())
+	}
+	if connections != 3 {
+		t.Errorf("expected 3 connections, got %d", connections)
+	}
+}
+
+func TestFollowLines(t *testing.T) {
+	var out bytes.Buffer
+	r := Response{out: &out, Filter: "id"}
+
+	body := bytes.NewBufferString("{\"id\": 1}\n\n\x1e{\"id\": 2}\n{\"other\": 3}\n{\"id\": 4}")
+	if err := r.followLines(body); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "1\n2\n4\n" {
+		t.Errorf("unexpected lines displayed %q", out.String())
+	}
+}
+
+func TestFollowEventsReconnectFails(t *testing.T) {
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		connections++
+		if connections == 1 {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "retry: 1\n\nid: 1\ndata: 1\n\n")
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	connect := func(string) (*http.Response, error) {
+		return http.Get(server.URL)
+	}
+
+	resp, err := connect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	r := Response{out: &out, reconnect: connect}
+	s := NewSettings()
+	s.Retries = sql.NullInt64{Int64: 1, Valid: true}
+
+	if err := r.Load(resp, s); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed reconnection is the result
+	if code := r.ExitCode(); code != 5 {
+		t.Errorf("expected exit code 5 got %d", code)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+var ErrFormData = errors.New("can't send request data and form fields in the same request")
+
+// hasForm reports if the request has a form body instead of raw data
+func (r *Request) hasForm() bool {
+	return len(r.Form) > 0 || len(r.Files) > 0
+}
+
+// formBody encodes the form fields, any files make it a multipart form
+func (r *Request) formBody() ([]byte, string, error) {
+	if r.Data != "" {
+		return nil, "", ErrFormData
+	}
+
+	if len(r.Files) == 0 {
+		values := url.Values{}
+		for key, value := range r.Form {
+			values.Set(key, value)
+		}
+
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	// sort the fields so the body is the same every time
+	for _, key := range sortedKeys(r.Form) {
+		if err := w.WriteField(key, r.Form[key]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, field := range sortedKeys(r.Files) {
+		if err := writeFormFile(w, field, r.Files[field]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func writeFormFile(w *multipart.Writer, field, path string) error {
+	path, err := homedir.Expand(os.ExpandEnv(strings.TrimPrefix(path, "@")))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(field), escapeQuotes(filepath.Base(path))))
+	h.Set("Content-Type", contentType)
+
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, f)
+	return err
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
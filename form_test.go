@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"testing"
+)
+
+func TestFormBody(t *testing.T) {
+	r := Request{Form: map[string]string{"name": "a b", "id": "1"}}
+	body, contentType, err := r.formBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+	if string(body) != "id=1&name=a+b" {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	f, err := ioutil.TempFile("", "rest-form-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("file contents")
+	f.Close()
+
+	r.Files = map[string]string{"upload": "@" + f.Name()}
+	body, contentType, err = r.formBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("unexpected content type %s", contentType)
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Value["name"][0] != "a b" {
+		t.Errorf("unexpected name field %v", form.Value["name"])
+	}
+
+	upload := form.File["upload"][0]
+	if ct := upload.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected file content type %s", ct)
+	}
+	part, err := upload.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, _ := ioutil.ReadAll(part)
+	if string(contents) != "file contents" {
+		t.Errorf("unexpected file contents %s", contents)
+	}
+
+	r.Data = "raw"
+	if _, _, err := r.formBody(); err != ErrFormData {
+		t.Errorf("expected form and data to conflict, got %v", err)
+	}
+}
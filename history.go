@@ -0,0 +1,522 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	// every performed request is kept in this top level bucket
+	historyBucket = "history"
+	// only the most recent requests are kept
+	historySize = 1000
+	// larger bodies are cut short so the history doesn't fill the db
+	historyBodySize = 1 << 20
+
+	redacted = "REDACTED"
+)
+
+var (
+	historyLimit  int
+	historyID     uint64
+	historyFormat string
+)
+
+func init() {
+	historyList.Flag("limit", "how many requests to list").Default("20").IntVar(&historyLimit)
+
+	historyShow.Arg("id", "the history entry to show").Required().Uint64Var(&historyID)
+	historyShow.Flag("format", "format of the entry, either text or json").
+		Default("text").
+		EnumVar(&historyFormat, "text", "json")
+
+	historyReplay.Arg("id", "the history entry to replay").Required().Uint64Var(&historyID)
+	requestFlags(historyReplay, false)
+}
+
+// ErrNoHistory is returned when there is no history entry with the id
+type ErrNoHistory struct {
+	ID uint64
+}
+
+func (e ErrNoHistory) Error() string {
+	return fmt.Sprintf("no history entry %d, use 'rest history list' to see the entries", e.ID)
+}
+
+// ErrIncompleteHistory is returned when replaying an entry whose request
+// body wasn't saved in full
+type ErrIncompleteHistory struct {
+	ID uint64
+}
+
+func (e ErrIncompleteHistory) Error() string {
+	return fmt.Sprintf("the request body of history entry %d wasn't saved in full, so it can't be replayed", e.ID)
+}
+
+// historyEntry is a performed request and its response, credentials are
+// redacted before it is saved
+type historyEntry struct {
+	ID      uint64    `json:"id"`
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Alias   string    `json:"alias,omitempty"`
+
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// FinalURL is where the request ended up after redirects
+	FinalURL string `json:"final_url,omitempty"`
+	// Path is relative to the service base path, so it can be replayed
+	// against another service
+	Path          string      `json:"path"`
+	Header        http.Header `json:"header,omitempty"`
+	Body          string      `json:"body,omitempty"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+	// StoredHeaders and StoredQueries came from the settings of the service,
+	// they aren't replayed so the service replayed against uses its own
+	StoredHeaders []string `json:"stored_headers,omitempty"`
+	StoredQueries []string `json:"stored_queries,omitempty"`
+
+	Status                int         `json:"status,omitempty"`
+	ResponseHeader        http.Header `json:"response_header,omitempty"`
+	ResponseBody          string      `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool        `json:"response_body_truncated,omitempty"`
+	Error                 string      `json:"error,omitempty"`
+
+	Duration float64          `json:"duration_ms"`
+	Timings  []*attemptTiming `json:"timings,omitempty"`
+}
+
+// record saves the request in the history, failing to save it doesn't fail
+// the request
+func (r *Request) record(httpResp *http.Response, resp *Response, err error, start time.Time) {
+	// nothing was sent
+	if r.req == nil {
+		return
+	}
+
+	keyName := r.Settings.AuthKeyName.String
+	entry := historyEntry{
+		Time:          start,
+		Service:       r.Service,
+		Alias:         r.Alias,
+		Method:        r.req.Method,
+		URL:           redactURL(r.req.URL, keyName),
+		Path:          r.relativePath(),
+		Header:        redactHeader(r.req.Header, keyName),
+		StoredHeaders: storedKeys(r.Settings.Headers, r.Flags.Headers, http.CanonicalHeaderKey),
+		StoredQueries: storedKeys(r.Settings.Queries, r.Flags.Queries, nil),
+		Duration:      ms(time.Since(start)),
+		Timings:       r.timings,
+	}
+
+	body, rerr := requestBody(r.req)
+	if rerr == nil {
+		entry.Body, entry.BodyTruncated = historyBody(body)
+	}
+
+	for _, timing := range entry.Timings {
+		if u, err := url.Parse(timing.URL); err == nil {
+			timing.URL = redactURL(u, keyName)
+		}
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if httpResp != nil {
+		// redirects change the url, the request url is kept for replaying
+		if httpResp.Request != nil {
+			if final := redactURL(httpResp.Request.URL, keyName); final != entry.URL {
+				entry.FinalURL = final
+			}
+		}
+		entry.Status = httpResp.StatusCode
+		entry.ResponseHeader = redactHeader(httpResp.Header)
+	}
+
+	if resp != nil {
+		entry.ResponseBody, entry.ResponseBodyTruncated = historyBody(resp.Raw)
+		// streamed bodies aren't kept in memory
+		if resp.streamed || resp.OutputFile != "" {
+			entry.ResponseBodyTruncated = true
+		}
+	}
+
+	if err := saveHistory(&entry); err != nil && r.verbose > 0 {
+		log.Println("not saving request to history:", err)
+	}
+}
+
+// storedKeys are the keys of the merged settings that weren't given as flags
+func storedKeys(merged, flags map[string]string, canonical func(string) string) []string {
+	keys := make([]string, 0)
+	for key := range merged {
+		if _, ok := flags[key]; ok {
+			continue
+		}
+		if canonical != nil {
+			key = canonical(key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// relativePath is the request path without the service base path
+func (r *Request) relativePath() string {
+	return trimBasePath(r.req.URL.Path, r.Settings.BasePath.String)
+}
+
+// trimBasePath removes the base path from the start of the path, only whole
+// segments are removed so /v10 isn't trimmed by the base path /v1
+func trimBasePath(p, base string) string {
+	p = strings.TrimPrefix(p, "/")
+	base = strings.Trim(base, "/")
+	if base != "" && (p == base || strings.HasPrefix(p, base+"/")) {
+		p = strings.TrimPrefix(p[len(base):], "/")
+	}
+	return p
+}
+
+// historyBody returns the body as a string, and if it was cut short.
+// Binary bodies aren't saved.
+func historyBody(body []byte) (string, bool) {
+	if !utf8.Valid(body) {
+		return "", true
+	}
+
+	if len(body) > historyBodySize {
+		return string(body[:historyBodySize]), true
+	}
+
+	return string(body), false
+}
+
+// sensitive reports if the header or query parameter probably holds a credential
+func sensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"auth", "token", "key", "secret", "password", "cookie", "signature", "session"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeader copies the header with the values of credentials replaced,
+// extra names are redacted as well
+func redactHeader(header http.Header, extra ...string) http.Header {
+	redact := make(http.Header, len(header))
+	for key, values := range header {
+		secret := sensitive(key)
+		for _, name := range extra {
+			secret = secret || (name != "" && strings.EqualFold(key, name))
+		}
+
+		if secret {
+			redact[key] = []string{redacted}
+			continue
+		}
+		redact[key] = append([]string{}, values...)
+	}
+	return redact
+}
+
+// redactURL returns the url with credentials in the query or user info
+// replaced, extra names are redacted as well
+func redactURL(u *url.URL, extra ...string) string {
+	c := *u
+	if c.User != nil {
+		c.User = url.User(redacted)
+	}
+
+	q := c.Query()
+	changed := false
+	for key := range q {
+		secret := sensitive(key)
+		for _, name := range extra {
+			secret = secret || (name != "" && key == name)
+		}
+
+		if secret {
+			q.Set(key, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		c.RawQuery = q.Encode()
+	}
+
+	return c.String()
+}
+
+func historyKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// saveHistory stores the entry with the next id, and drops the oldest
+// entries when there are too many
+func saveHistory(entry *historyEntry) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+		if err != nil {
+			return err
+		}
+
+		entry.ID, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put(historyKey(entry.ID), data); err != nil {
+			return err
+		}
+
+		if entry.ID <= historySize {
+			return nil
+		}
+
+		oldest := entry.ID - historySize
+		c := b.Cursor()
+		for key, _ := c.First(); key != nil && binary.BigEndian.Uint64(key) <= oldest; key, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func loadHistory(id uint64) (historyEntry, error) {
+	var entry historyEntry
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return ErrNoHistory{ID: id}
+		}
+
+		data := b.Get(historyKey(id))
+		if data == nil {
+			return ErrNoHistory{ID: id}
+		}
+
+		return json.Unmarshal(data, &entry)
+	})
+
+	return entry, err
+}
+
+// listHistory writes the most recent entries, newest first
+func listHistory(w io.Writer, limit int) error {
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		count := 0
+		for key, data := c.Last(); key != nil && count < limit; key, data = c.Prev() {
+			var entry historyEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+
+			outcome := fmt.Sprint(entry.Status)
+			if entry.Error != "" {
+				outcome = "failed"
+			}
+
+			name := entry.Service
+			if entry.Alias != "" {
+				name += " " + entry.Alias
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s %s\t%s\t%.2fms\n",
+				entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"), name,
+				entry.Method, entry.URL, outcome, entry.Duration)
+			count++
+		}
+
+		return nil
+	})
+}
+
+func clearHistory() error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(historyBucket)) == nil {
+			return nil
+		}
+
+		return tx.DeleteBucket([]byte(historyBucket))
+	})
+}
+
+// Write the entry as text or json
+func (e historyEntry) Write(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(e)
+	}
+
+	name := e.Service
+	if e.Alias != "" {
+		name += ", alias " + e.Alias
+	}
+	fmt.Fprintf(w, "#%d %s %s\n\n", e.ID, e.Time.Local().Format(time.RFC1123), name)
+
+	fmt.Fprintf(w, "%s %s\n", e.Method, e.URL)
+	if e.FinalURL != "" {
+		fmt.Fprintf(w, "redirected to %s\n", e.FinalURL)
+	}
+	writeHistoryHeader(w, e.Header)
+	writeHistoryBody(w, e.Body, e.BodyTruncated)
+
+	if e.Error != "" {
+		fmt.Fprintf(w, "error: %s\n\n", e.Error)
+	} else {
+		fmt.Fprintf(w, "%d %s\n", e.Status, http.StatusText(e.Status))
+		writeHistoryHeader(w, e.ResponseHeader)
+		writeHistoryBody(w, e.ResponseBody, e.ResponseBodyTruncated)
+	}
+
+	for _, timing := range e.Timings {
+		if err := timing.Write(w, "text"); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "duration: %.2fms\n", e.Duration)
+	return err
+}
+
+func writeHistoryHeader(w io.Writer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s: %s\n", key, strings.Join(header[key], ", "))
+	}
+	fmt.Fprintln(w)
+}
+
+func writeHistoryBody(w io.Writer, body string, truncated bool) {
+	if body != "" {
+		fmt.Fprint(w, body)
+		if !strings.HasSuffix(body, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+	if truncated {
+		fmt.Fprintln(w, "[body not saved in full]")
+	}
+	if body != "" || truncated {
+		fmt.Fprintln(w)
+	}
+}
+
+// replayRequest builds a request from the history entry, the service
+// settings, such as the host and credentials, are those of the service
+// the request is replayed against
+func replayRequest(entry historyEntry, r *Request) (*Request, error) {
+	if entry.BodyTruncated {
+		return nil, ErrIncompleteHistory{ID: entry.ID}
+	}
+
+	r.Method = strings.ToLower(entry.Method)
+	r.Path = entry.Path
+	r.Alias = entry.Alias
+
+	// a body starting with @ would be read as a file
+	r.Data = entry.Body
+	if strings.HasPrefix(r.Data, "@") {
+		r.Data = "@" + r.Data
+	}
+
+	stored := make(map[string]bool)
+	for _, key := range entry.StoredHeaders {
+		stored["header "+key] = true
+	}
+	for _, key := range entry.StoredQueries {
+		stored["query "+key] = true
+	}
+
+	for key, values := range entry.Header {
+		switch {
+		case values[0] == redacted:
+		// the service replayed against has its own stored headers
+		case stored["header "+key]:
+		// the cache adds these when it has the response
+		case key == "If-None-Match", key == "If-Modified-Since":
+		// the transport adds these
+		case key == "Content-Length", key == "User-Agent", key == "Accept-Encoding":
+		default:
+			r.Flags.Headers[key] = values[0]
+		}
+	}
+
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range u.Query() {
+		if values[0] != redacted && !stored["query "+key] {
+			r.Flags.Queries[key] = values[0]
+		}
+	}
+
+	return r, nil
+}
+
+// Replay performs the history entry again, and exits
+func Replay() error {
+	entry, err := loadHistory(historyID)
+	if err != nil {
+		return err
+	}
+
+	r, err := replayRequest(entry, newRequest())
+	if err != nil {
+		return err
+	}
+
+	run(r)
+	return nil
+}
+
+// ShowHistory writes the history entry to stdout
+func ShowHistory() error {
+	entry, err := loadHistory(historyID)
+	if err != nil {
+		return err
+	}
+
+	return entry.Write(os.Stdout, historyFormat)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestHistory(t *testing.T) {
+	defer testDB(t, "history")()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/users/1" {
+			http.Redirect(w, req, "/api/people/1?moved=yes", http.StatusFound)
+			return
+		}
+		w.Header().Set("Set-Cookie", "session=1")
+		fmt.Fprint(w, `{"id": 1}`)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host, port, _ := strings.Cut(u.Host, ":")
+	p, _ := strconv.Atoi(port)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		s := NewSettings()
+		s.Scheme = sql.NullString{String: "http", Valid: true}
+		s.Host = sql.NullString{String: host, Valid: true}
+		s.Port = sql.NullInt64{Int64: int64(p), Valid: true}
+		s.BasePath = sql.NullString{String: "api", Valid: true}
+		s.Headers["Authorization"] = "Bearer secret"
+		s.Headers["X-Trace"] = "1"
+		s.Queries["api_key"] = "secret"
+		s.Queries["page"] = "2"
+		s.AuthType = sql.NullString{String: "api-key", Valid: true}
+		s.AuthKeyName = sql.NullString{String: "appid", Valid: true}
+		s.AuthKey = sql.NullString{String: "SECRET123", Valid: true}
+		s.AuthKeyIn = sql.NullString{String: "query", Valid: true}
+		return s.Write(getBucket(tx, "services.history"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Request{Service: "history", Method: "get", Path: "users/1", Flags: NewSettings()}
+	r.Flags.Headers["X-Request"] = "abc"
+	r.Flags.Queries["q"] = "name"
+	if err := r.Execute(&Response{}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := loadHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Path != "users/1" || entry.Status != 200 || entry.ResponseBody != `{"id": 1}` || len(entry.Timings) != 1 {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if entry.Header.Get("Authorization") != redacted || entry.ResponseHeader.Get("Set-Cookie") != redacted {
+		t.Errorf("expected credentials to be redacted %v %v", entry.Header, entry.ResponseHeader)
+	}
+	if strings.Contains(entry.URL, "secret") || strings.Contains(entry.URL, "SECRET123") || !strings.Contains(entry.URL, "page=2") {
+		t.Errorf("expected the api key to be redacted from %s", entry.URL)
+	}
+	if !strings.Contains(entry.URL, "/api/users/1?") || !strings.Contains(entry.FinalURL, "/api/people/1?moved=yes") {
+		t.Errorf("expected the request and redirected urls got %s and %s", entry.URL, entry.FinalURL)
+	}
+	for _, timing := range entry.Timings {
+		if strings.Contains(timing.URL, "SECRET123") {
+			t.Errorf("expected the api key to be redacted from the timing %s", timing.URL)
+		}
+	}
+
+	// the credentials and stored settings come from the service it is
+	// replayed against, only what was given for the request is replayed
+	replay, err := replayRequest(entry, &Request{Flags: NewSettings()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Method != "get" || replay.Path != "users/1" {
+		t.Errorf("unexpected replay %s %s", replay.Method, replay.Path)
+	}
+	if len(replay.Flags.Headers) != 1 || replay.Flags.Headers["X-Request"] != "abc" {
+		t.Errorf("unexpected replay headers %v", replay.Flags.Headers)
+	}
+	if len(replay.Flags.Queries) != 1 || replay.Flags.Queries["q"] != "name" {
+		t.Errorf("unexpected replay queries %v", replay.Flags.Queries)
+	}
+
+	// only the most recent entries are kept
+	db.DB.NoSync = true
+	for i := 0; i < historySize; i++ {
+		if err := saveHistory(&historyEntry{Method: "GET", URL: "/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := loadHistory(1); err == nil {
+		t.Error("expected the oldest entry to be dropped")
+	}
+	if _, err := loadHistory(historySize + 1); err != nil {
+		t.Error(err)
+	}
+
+	var list bytes.Buffer
+	if err := listHistory(&list, 3); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(list.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], strconv.Itoa(historySize+1)+"\t") {
+		t.Errorf("expected the 3 newest entries got %q", list.String())
+	}
+}
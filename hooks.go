@@ -8,7 +8,32 @@ import (
 	gopherjson "layeh.com/gopher-json"
 )
 
-var luaState *lua.LState
+// luaEnv is the lua environment the hooks of a single request run in, the
+// request and its response share one so hooks can see earlier hooks variables
+type luaEnv struct {
+	L *lua.LState
+}
+
+// state returns the lua state, creating it on first use
+func (e *luaEnv) state() (*lua.LState, error) {
+	if e.L == nil {
+		e.L = lua.NewState()
+		gopherjson.Preload(e.L)
+		if err := e.L.DoString(`json = require("json")`); err != nil {
+			return nil, ErrHook{Context: "loading json helper", Err: err}
+		}
+		if err := e.L.DoString(luaHelpers); err != nil {
+			return nil, ErrHook{Context: "loading table helpers", Err: err}
+		}
+	}
+	return e.L, nil
+}
+
+func (e *luaEnv) Close() {
+	if e.L != nil {
+		e.L.Close()
+	}
+}
 
 type ErrHook struct {
 	Context string
@@ -24,7 +49,10 @@ func (r *Response) hook() error {
 		return nil
 	}
 
-	L, err := initLua()
+	if r.lua == nil {
+		r.lua = &luaEnv{}
+	}
+	L, err := r.lua.state()
 	if err != nil {
 		return err
 	}
@@ -100,7 +128,10 @@ func (r *Request) dataHook() error {
 		return nil
 	}
 
-	L, err := initLua()
+	if r.lua == nil {
+		r.lua = &luaEnv{}
+	}
+	L, err := r.lua.state()
 	if err != nil {
 		return err
 	}
@@ -118,7 +149,10 @@ func (r *Request) hook() error {
 		return nil
 	}
 
-	L, err := initLua()
+	if r.lua == nil {
+		r.lua = &luaEnv{}
+	}
+	L, err := r.lua.state()
 	if err != nil {
 		return err
 	}
@@ -133,9 +167,11 @@ func (r *Request) hook() error {
 	t.RawSetString("queries", q)
 	h := L.NewTable()
 	for key, value := range r.Settings.Headers {
-		q.RawSetString(key, lua.LString(value))
+		h.RawSetString(key, lua.LString(value))
 	}
 	t.RawSetString("headers", h)
+	t.RawSetString("form", stringMapToLua(L, r.Form))
+	t.RawSetString("files", stringMapToLua(L, r.Files))
 	L.SetGlobal("request", t)
 
 	if err := L.DoString(r.RequestHook); err != nil {
@@ -169,9 +205,37 @@ func (r *Request) hook() error {
 		r.Settings.Headers[key.String()] = value.String()
 	})
 
+	form, ok := t.RawGetString("form").(*lua.LTable)
+	if !ok {
+		return ErrHook{Context: "returning request", Err: errors.New("expected a table in form")}
+	}
+	r.Form = luaToStringMap(form)
+
+	files, ok := t.RawGetString("files").(*lua.LTable)
+	if !ok {
+		return ErrHook{Context: "returning request", Err: errors.New("expected a table in files")}
+	}
+	r.Files = luaToStringMap(files)
+
 	return nil
 }
 
+func stringMapToLua(L *lua.LState, m map[string]string) *lua.LTable {
+	t := L.NewTable()
+	for key, value := range m {
+		t.RawSetString(key, lua.LString(value))
+	}
+	return t
+}
+
+func luaToStringMap(t *lua.LTable) map[string]string {
+	m := make(map[string]string)
+	t.ForEach(func(key, value lua.LValue) {
+		m[key.String()] = value.String()
+	})
+	return m
+}
+
 func stringSliceToLua(L *lua.LState, s []string) *lua.LTable {
 	v := L.NewTable()
 	for i := range s {
@@ -180,20 +244,6 @@ func stringSliceToLua(L *lua.LState, s []string) *lua.LTable {
 	return v
 }
 
-func initLua() (*lua.LState, error) {
-	if luaState == nil {
-		luaState = lua.NewState()
-		gopherjson.Preload(luaState)
-		if err := luaState.DoString(`json = require("json")`); err != nil {
-			return nil, ErrHook{Context: "loading json helper", Err: err}
-		}
-		if err := luaState.DoString(luaHelpers); err != nil {
-			return nil, ErrHook{Context: "loading table helpers", Err: err}
-		}
-	}
-	return luaState, nil
-}
-
 var luaHelpers = `
 -- found at https://svn.wildfiregames.com/public/ps/trunk/build/premake/premake4/src/base/table.lua
 --
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRequestHookHeaders(t *testing.T) {
+	r := Request{Settings: NewSettings()}
+	r.Settings.Headers["X-One"] = "1"
+	r.RequestHook = `request.headers["X-Two"] = request.headers["X-One"] .. "2"`
+	defer func() {
+		if r.lua != nil {
+			r.lua.Close()
+		}
+	}()
+
+	if err := r.hook(); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Settings.Headers["X-One"] != "1" || r.Settings.Headers["X-Two"] != "12" {
+		t.Errorf("unexpected headers after the hook %v", r.Settings.Headers)
+	}
+	if r.URL.Query().Get("X-One") != "" {
+		t.Errorf("expected headers not to be sent as queries %s", r.URL.RawQuery)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// the limiter state for every service and limit is kept in this top level bucket
+const limitsBucket = "limits"
+
+// ErrRateLimit is returned when the rate limit setting can't be parsed
+type ErrRateLimit struct {
+	Value string
+}
+
+func (e ErrRateLimit) Error() string {
+	return fmt.Sprintf("invalid rate limit %s, expected a number of requests per s, m, or h, eg. 10/s", e.Value)
+}
+
+// parseRate converts a rate like 10/s, 100/m, or 5000/h into requests per
+// second, a plain number is per second
+func parseRate(value string) (float64, error) {
+	count, unit, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		unit = "s"
+	}
+
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return 0, ErrRateLimit{Value: value}
+	}
+
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	}
+
+	return 0, ErrRateLimit{Value: value}
+}
+
+// limit waits until the service rate limit allows another request, and
+// returns how long it waited.  Every attempt is limited, so retries, pages,
+// and batch rows all count.
+func (r *Request) limit() (time.Duration, error) {
+	if !r.Settings.RateLimit.Valid || r.Settings.RateLimit.String == "" {
+		return 0, nil
+	}
+
+	rate, err := parseRate(r.Settings.RateLimit.String)
+	if err != nil {
+		return 0, err
+	}
+
+	burst := r.Settings.RateBurst.Int64
+	if burst < 1 {
+		burst = 1
+	}
+
+	wait, err := takeToken(r.Service, rate, float64(burst), time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if wait > 0 {
+		if r.verbose > 0 {
+			log.Printf("rate limit: delaying request by %s\n", wait.Round(time.Millisecond))
+		}
+		time.Sleep(wait)
+	}
+
+	return wait, nil
+}
+
+// takeToken takes a token from the services token bucket, and returns how
+// long to wait before using it.  The state is stored in the db so separate
+// processes share the limit.  Paths and aliases can set their own limit, so
+// each rate and burst gets its own bucket.  Tokens are reserved even when they aren't
+// available yet, so requests that wait are queued behind each other.
+func takeToken(service string, rate, burst float64, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(limitsBucket))
+		if err != nil {
+			return err
+		}
+
+		key := limitKey(service, rate, burst)
+		tokens, last := burst, now
+		if state := b.Get([]byte(key)); state != nil {
+			tokens, last = parseLimitState(string(state), burst, now)
+		}
+
+		// refill for the time that has passed, the bucket never holds more than the burst
+		if elapsed := now.Sub(last); elapsed > 0 {
+			tokens += elapsed.Seconds() * rate
+			last = now
+		}
+		if tokens > burst {
+			tokens = burst
+		}
+
+		tokens--
+		if tokens < 0 {
+			wait = time.Duration(-tokens / rate * float64(time.Second))
+		}
+
+		state := strconv.FormatFloat(tokens, 'g', -1, 64) + " " + strconv.FormatInt(last.UnixNano(), 10)
+		return b.Put([]byte(key), []byte(state))
+	})
+
+	return wait, err
+}
+
+// limitKey is where the state for the service limit is kept
+func limitKey(service string, rate, burst float64) string {
+	return fmt.Sprintf("%s %g/s %g", service, rate, burst)
+}
+
+// parseLimitState reads the stored tokens and time, a corrupt state starts a full bucket
+func parseLimitState(state string, burst float64, now time.Time) (float64, time.Time) {
+	tokensField, lastField, found := strings.Cut(state, " ")
+	if !found {
+		return burst, now
+	}
+
+	tokens, err := strconv.ParseFloat(tokensField, 64)
+	if err != nil {
+		return burst, now
+	}
+
+	last, err := strconv.ParseInt(lastField, 10, 64)
+	if err != nil {
+		return burst, now
+	}
+
+	return tokens, time.Unix(0, last)
+}
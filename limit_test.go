@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]float64{
+		"10":     10,
+		"10/s":   10,
+		"120/m":  2,
+		"3600/h": 1,
+	}
+
+	for value, expected := range tests {
+		rate, err := parseRate(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rate != expected {
+			t.Errorf("%s: expected %v got %v", value, expected, rate)
+		}
+	}
+
+	for _, value := range []string{"", "0/s", "fast", "10/d"} {
+		if _, err := parseRate(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestTakeToken(t *testing.T) {
+	defer testDB(t, "limited")()
+
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at   time.Duration
+		wait time.Duration
+	}{
+		// the burst is available straight away
+		{0, 0},
+		{0, 0},
+		// then requests queue up behind each other
+		{0, 500 * time.Millisecond},
+		{0, time.Second},
+		{250 * time.Millisecond, 1250 * time.Millisecond},
+		// an idle bucket only refills up to the burst
+		{time.Minute, 0},
+		{time.Minute, 0},
+		{time.Minute, 500 * time.Millisecond},
+	}
+
+	for i, step := range steps {
+		wait, err := takeToken("limited", 2, 2, now.Add(step.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != step.wait {
+			t.Errorf("step %d: expected to wait %s got %s", i, step.wait, wait)
+		}
+	}
+
+	// a different limit, from a path or alias, has its own bucket
+	wait, err := takeToken("limited", 1, 1, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != 0 {
+		t.Errorf("expected a separate limit not to wait got %s", wait)
+	}
+}
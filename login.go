@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var loginTimeout time.Duration
+
+func init() {
+	login.Flag("login-timeout", "how long to wait for the authorization callback").
+		Default("5m").
+		DurationVar(&loginTimeout)
+	settings.Flags(login, false)
+}
+
+// ErrLoginCallback is returned when the authorization server redirects back with an error
+type ErrLoginCallback struct {
+	Code        string
+	Description string
+}
+
+func (e ErrLoginCallback) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("authorization failed: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("authorization failed: %s", e.Code)
+}
+
+// ErrLoginAuthType is returned when the service already uses another auth type
+type ErrLoginAuthType struct {
+	Type string
+}
+
+func (e ErrLoginAuthType) Error() string {
+	return fmt.Sprintf("the service uses %s auth, log in with --auth-type oauth2 to replace it", e.Type)
+}
+
+var (
+	ErrLoginState   = errors.New("authorization callback state did not match, the login may have been tampered with")
+	ErrLoginTimeout = errors.New("timed out waiting for the authorization callback")
+)
+
+// loginService performs the oauth2 authorization code flow for the current service
+func loginService() error {
+	r := newRequest()
+	if err := db.View(r.LoadSettings); err != nil {
+		return err
+	}
+
+	return loginOAuth2(r, os.Stdout, nil)
+}
+
+// loginOAuth2 starts a loopback listener to receive the authorization code,
+// then exchanges it for tokens using PKCE.  The authorization URL is written
+// to out, if browse is set it is called with the URL to open it.
+func loginOAuth2(r *Request, out io.Writer, browse func(string) error) error {
+	if t := r.Settings.AuthType.String; t != "" && t != "oauth2" {
+		return ErrLoginAuthType{Type: t}
+	}
+
+	r.Settings.AuthType = sql.NullString{String: "oauth2", Valid: true}
+	r.Settings.AuthGrant = sql.NullString{String: "authorization-code", Valid: true}
+
+	provider, err := r.Auth(replacer(r.Settings.Parameters))
+	if err != nil {
+		return err
+	}
+	a := provider.(*oauth2Auth)
+
+	if a.authURL == "" {
+		return ErrAuthSetting{Type: "oauth2 login", Setting: "auth-url"}
+	}
+
+	redirect, err := url.Parse(a.redirectURL)
+	if err != nil {
+		return err
+	}
+	if a.redirectURL == "" {
+		redirect = &url.URL{Scheme: "http", Host: "127.0.0.1:0"}
+	}
+	if redirect.Path == "" {
+		redirect.Path = "/callback"
+	}
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	// the port may have been picked for us
+	redirect.Host = listener.Addr().String()
+
+	verifier := base64.RawURLEncoding.EncodeToString([]byte(randomHex()))
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	state := randomHex()
+
+	authURL, err := url.Parse(a.authURL)
+	if err != nil {
+		return err
+	}
+	q := authURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", a.clientID)
+	q.Set("redirect_uri", redirect.String())
+	q.Set("state", state)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	if a.scopes != "" {
+		q.Set("scope", a.scopes)
+	}
+	authURL.RawQuery = q.Encode()
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+
+	// only the first callback is used, later ones must not block the
+	// handler.  The browser may ask for other paths, such as the favicon,
+	// which aren't the callback.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != redirect.Path {
+			http.NotFound(w, req)
+			return
+		}
+
+		values := req.URL.Query()
+		switch {
+		case values.Get("state") != state:
+			http.Error(w, ErrLoginState.Error(), http.StatusBadRequest)
+			select {
+			case errs <- ErrLoginState:
+			default:
+			}
+		case values.Get("error") != "":
+			err := ErrLoginCallback{Code: values.Get("error"), Description: values.Get("error_description")}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			select {
+			case errs <- err:
+			default:
+			}
+		default:
+			fmt.Fprintln(w, "logged in, you can close this window")
+			select {
+			case codes <- values.Get("code"):
+			default:
+			}
+		}
+	})
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	fmt.Fprintf(out, "open the following URL to log in to %s:\n\n%s\n\n", r.Service, authURL)
+	if browse != nil {
+		if err := browse(authURL.String()); err != nil {
+			return err
+		}
+	}
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		return err
+	case <-time.After(loginTimeout):
+		return ErrLoginTimeout
+	}
+
+	// the callback was received so we can start from a clean token
+	a.token = oauth2Token{}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirect.String())
+	form.Set("code_verifier", verifier)
+
+	if err := a.requestToken(form); err != nil {
+		return err
+	}
+
+	// store the grant so requests know to refresh instead of using client
+	// credentials, along with the oauth2 settings given to log in so the
+	// token can be refreshed
+	err = db.Update(func(tx *bolt.Tx) error {
+		sb, err := r.ServiceBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		s := NewSettings()
+		s.AuthType = r.Settings.AuthType
+		s.AuthGrant = r.Settings.AuthGrant
+		s.AuthURL = r.Flags.AuthURL
+		s.AuthTokenURL = r.Flags.AuthTokenURL
+		s.AuthClientID = r.Flags.AuthClientID
+		s.AuthClientSecret = r.Flags.AuthClientSecret
+		s.AuthClientAuth = r.Flags.AuthClientAuth
+		s.AuthScopes = r.Flags.AuthScopes
+		s.AuthRedirectURL = r.Flags.AuthRedirectURL
+		return s.Write(sb)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "logged in to %s\n", r.Service)
+	return nil
+}
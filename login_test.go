@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLoginOAuth2(t *testing.T) {
+	defer testDB(t, "login")()
+	loginTimeout = 5 * time.Second
+
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		if q.Get("client_id") != "cli" || q.Get("code_challenge_method") != "S256" {
+			t.Errorf("unexpected authorization request %s", req.URL)
+		}
+		challenge = q.Get("code_challenge")
+
+		redirect, _ := url.Parse(q.Get("redirect_uri"))
+		rq := redirect.Query()
+		rq.Set("code", "authcode")
+		rq.Set("state", q.Get("state"))
+		redirect.RawQuery = rq.Encode()
+		http.Redirect(w, req, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		sum := sha256.Sum256([]byte(req.FormValue("code_verifier")))
+		if req.FormValue("code") != "authcode" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"expires_in":    60,
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// the settings are given as flags to the login command
+	r := &Request{Service: "login", Flags: NewSettings()}
+	r.Flags.AuthURL = sql.NullString{String: server.URL + "/authorize", Valid: true}
+	r.Flags.AuthTokenURL = sql.NullString{String: server.URL + "/token", Valid: true}
+	r.Flags.AuthClientID = sql.NullString{String: "cli", Valid: true}
+	r.Settings = r.Flags.Clone()
+
+	// the browser follows the redirect back to the loopback listener, and
+	// asks for the favicon which isn't the callback
+	browse := func(u string) error {
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				favicon := *req.URL
+				favicon.Path, favicon.RawQuery = "/favicon.ico", ""
+				resp, err := http.Get(favicon.String())
+				if err != nil {
+					return err
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusNotFound {
+					t.Errorf("expected 404 for the favicon got %s", resp.Status)
+				}
+				return nil
+			},
+		}
+
+		resp, err := client.Get(u)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	var out bytes.Buffer
+	if err := loginOAuth2(r, &out, browse); err != nil {
+		t.Fatal(err)
+	}
+
+	key := (&oauth2Auth{tokenURL: server.URL + "/token", clientID: "cli"}).cacheKey()
+	token, err := loadOAuth2Token("login", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || token.Expiry.IsZero() {
+		t.Fatalf("unexpected token %+v", token)
+	}
+
+	// later requests have what they need to refresh the token
+	stored := &Request{Service: "login"}
+	if err := db.View(stored.LoadSettings); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Settings.AuthTokenURL != r.Flags.AuthTokenURL || stored.Settings.AuthClientID != r.Flags.AuthClientID {
+		t.Errorf("expected the login settings to be stored got %+v", stored.Settings)
+	}
+	if _, err := stored.Auth(replacer(nil)); err != nil {
+		t.Error(err)
+	}
+
+	// another auth type isn't replaced without asking
+	r.Settings.AuthType = sql.NullString{String: "bearer", Valid: true}
+	if err := loginOAuth2(r, &out, browse); err == nil {
+		t.Error("expected logging in to a bearer auth service to fail")
+	}
+}
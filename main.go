@@ -4,6 +4,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -18,8 +19,9 @@ var (
 
 	db = &DB{}
 
-	request  Request
-	response Response
+	// request holds what was parsed from the command line, it is copied by
+	// newRequest and never changed while performing requests
+	request Request
 
 	service string
 )
@@ -38,6 +40,7 @@ func init() {
 
 func main() {
 	command := kingpin.Parse()
+	settings.socketDefaults()
 
 	if verbLevel > 1 && request.Service != "" {
 		log.Println("using ", request.Service)
@@ -48,10 +51,6 @@ func main() {
 		os.Exit(1)
 	}
 	defer db.Close()
-	if luaState != nil {
-		defer luaState.Close()
-	}
-
 	switch command {
 	case "version":
 		displayVersion()
@@ -91,6 +90,21 @@ func main() {
 			log.Println(err)
 			os.Exit(1)
 		}
+	case "service login":
+		if err := loginService(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	case "service import":
+		if err := ImportPostman(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	case "service export":
+		if err := ExportPostman(os.Stdout); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 	case "service alias":
 		if err := addAlias(); err != nil {
 			log.Println(err)
@@ -100,6 +114,47 @@ func main() {
 	case "get", "post", "put", "delete", "patch", "options", "head":
 		Do(command)
 
+	case "ws":
+		Session(newRequest())
+
+	case "batch":
+		if err := Batch(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+	case "bench":
+		if err := Bench(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+	case "history list":
+		if err := listHistory(os.Stdout, historyLimit); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	case "history show":
+		if err := ShowHistory(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	case "history replay":
+		if err := Replay(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	case "history clear":
+		if err := clearHistory(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+	case "diff history":
+		Diff(DiffHistory)
+	case "diff alias":
+		Diff(DiffServices)
+
 	default:
 		Perform(command)
 	}
@@ -107,30 +162,44 @@ func main() {
 
 // Do perform the request, display the response, and exit.
 func Do(command string) {
-	request.Method = command
-	request.verbose = verbLevel
+	r := newRequest()
+	r.Method = command
+	run(r)
+}
 
-	resp, err := request.Perform()
-	if err != nil {
-		log.Println("error making request:", err)
-		if resp != nil {
-			log.Println(resp.Body)
-			resp.Body.Close()
+// run performs the request, displays the response, and exits
+func run(r *Request) {
+	var response Response
+	if err := r.Execute(&response); err != nil {
+		if err == ErrDryRun {
+			os.Exit(0)
 		}
+		log.Println(err)
 		os.Exit(1)
 	}
 
-	response.verbose = verbLevel
-	if err := response.Load(resp, request.Settings); err != nil {
+	if err := response.Display(os.Stdout); err != nil {
 		log.Println("error displaying result:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println(response)
-
 	os.Exit(response.ExitCode())
 }
 
+// Diff writes the differences between the responses, and exits with 2 when
+// they differ
+func Diff(compare func(io.Writer) (bool, error)) {
+	differs, err := compare(os.Stdout)
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	if differs {
+		os.Exit(2)
+	}
+}
+
 // currentService returns the currently selected service, it first checks if the --service command line flag
 // has been set, after that it checks in the local db for the current service, if no service is selected returns
 // a empty string
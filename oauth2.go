@@ -0,0 +1,367 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// tokens are refreshed this long before they expire so they don't expire in flight
+const oauth2ExpiryDelta = 30 * time.Second
+
+// ErrOAuth2 is returned when the token endpoint refuses to issue a token
+type ErrOAuth2 struct {
+	Status      int
+	Code        string
+	Description string
+}
+
+func (e ErrOAuth2) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2 token request failed with %d: %s: %s", e.Status, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2 token request failed with %d: %s", e.Status, e.Code)
+}
+
+// ErrLoginRequired is returned when the authorization-code grant has no token to refresh
+type ErrLoginRequired struct {
+	Service string
+}
+
+func (e ErrLoginRequired) Error() string {
+	return fmt.Sprintf("not logged in to %s, run 'rest service login'", e.Service)
+}
+
+// oauth2Token is the token cached in the service bucket
+type oauth2Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+func (t oauth2Token) valid(now time.Time) bool {
+	if t.AccessToken == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || now.Add(oauth2ExpiryDelta).Before(t.Expiry)
+}
+
+// legacyOAuth2Keys were stored directly in the oauth2 bucket, before tokens
+// were cached per client
+var legacyOAuth2Keys = []string{"access-token", "token-type", "refresh-token", "expiry"}
+
+// loadOAuth2Token reads the cached token for the service and client
+func loadOAuth2Token(service, key string) (oauth2Token, error) {
+	var t oauth2Token
+	err := db.View(func(tx *bolt.Tx) error {
+		b := getBucket(tx, fmt.Sprintf("services.%s.oauth2.%s", service, key))
+		if b == nil {
+			return nil
+		}
+
+		t.AccessToken = string(b.Get([]byte("access-token")))
+		t.TokenType = string(b.Get([]byte("token-type")))
+		t.RefreshToken = string(b.Get([]byte("refresh-token")))
+		if expiry := b.Get([]byte("expiry")); expiry != nil {
+			var err error
+			t.Expiry, err = time.Parse(time.RFC3339, string(expiry))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return t, err
+}
+
+// storeOAuth2Token caches the token for the client in the service bucket
+func storeOAuth2Token(service, key string, t oauth2Token) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		sb := getBucket(tx, fmt.Sprintf("services.%s", service))
+		if sb == nil {
+			return ErrNoService{Name: service}
+		}
+
+		ob, err := sb.CreateBucketIfNotExists([]byte("oauth2"))
+		if err != nil {
+			return err
+		}
+
+		// bolt refuses to delete a missing key when the next key is a bucket,
+		// so only delete the legacy keys that are there
+		for _, legacy := range legacyOAuth2Keys {
+			if ob.Get([]byte(legacy)) == nil {
+				continue
+			}
+			if err := ob.Delete([]byte(legacy)); err != nil {
+				return err
+			}
+		}
+
+		if err := ob.DeleteBucket([]byte(key)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		b, err := ob.CreateBucket([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		values := map[string]string{
+			"access-token":  t.AccessToken,
+			"token-type":    t.TokenType,
+			"refresh-token": t.RefreshToken,
+		}
+		if !t.Expiry.IsZero() {
+			values["expiry"] = t.Expiry.Format(time.RFC3339)
+		}
+
+		for key, value := range values {
+			if value == "" {
+				continue
+			}
+
+			if err := b.Put([]byte(key), []byte(value)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// oauth2Auth fetches tokens from the token endpoint, and caches them in the
+// service bucket so they can be used across invocations.  The settings can
+// differ between paths and aliases, so tokens are cached per token url,
+// client, and scopes.
+type oauth2Auth struct {
+	service      string
+	tokenURL     string
+	authURL      string
+	redirectURL  string
+	clientID     string
+	clientSecret string
+	clientAuth   string
+	grant        string
+	scopes       string
+	refreshToken string
+
+	client  *http.Client
+	verbose int
+
+	token  oauth2Token
+	loaded bool
+}
+
+func newOAuth2Auth(r *Request, replace func(string) string) (AuthProvider, error) {
+	s := r.Settings
+	if s.AuthTokenURL.String == "" {
+		return nil, ErrAuthSetting{Type: "oauth2", Setting: "auth-token-url"}
+	}
+
+	a := &oauth2Auth{
+		service:      r.Service,
+		tokenURL:     replace(s.AuthTokenURL.String),
+		authURL:      replace(s.AuthURL.String),
+		redirectURL:  replace(s.AuthRedirectURL.String),
+		clientID:     replace(s.AuthClientID.String),
+		clientSecret: replace(s.AuthClientSecret.String),
+		clientAuth:   s.AuthClientAuth.String,
+		grant:        s.AuthGrant.String,
+		scopes:       replace(s.AuthScopes.String),
+		refreshToken: replace(s.AuthRefreshToken.String),
+		verbose:      r.verbose,
+	}
+
+	switch a.grant {
+	case "":
+		a.grant = "client-credentials"
+	case "client-credentials", "refresh-token", "authorization-code":
+	default:
+		return nil, ErrAuthSetting{Type: "oauth2", Setting: "auth-grant to client-credentials, refresh-token, or authorization-code"}
+	}
+
+	if a.grant != "refresh-token" && a.clientID == "" {
+		return nil, ErrAuthSetting{Type: "oauth2", Setting: "auth-client-id"}
+	}
+
+	switch a.clientAuth {
+	case "", "header", "body":
+	default:
+		return nil, ErrAuthSetting{Type: "oauth2", Setting: "auth-client-auth to header or body"}
+	}
+
+	var err error
+	a.client, err = s.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *oauth2Auth) Apply(req *http.Request) error {
+	if !a.loaded {
+		var err error
+		a.token, err = loadOAuth2Token(a.service, a.cacheKey())
+		if err != nil {
+			return err
+		}
+		a.loaded = true
+	}
+
+	if !a.token.valid(time.Now()) {
+		if err := a.fetch(); err != nil {
+			return err
+		}
+	}
+
+	tokenType := a.token.TokenType
+	// some servers return the type in lower case, but expect it capitalised
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	req.Header.Set("Authorization", tokenType+" "+a.token.AccessToken)
+	return nil
+}
+
+// Challenge forces a token refresh, the token may have been revoked before it expired
+func (a *oauth2Auth) Challenge(req *http.Request, resp *http.Response) (bool, error) {
+	if a.verbose > 0 {
+		log.Println("token rejected, refreshing oauth2 token")
+	}
+
+	a.token = oauth2Token{RefreshToken: a.token.RefreshToken}
+	if err := a.fetch(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// fetch a new token from the token endpoint and cache it
+func (a *oauth2Auth) fetch() error {
+	form := url.Values{}
+
+	switch a.grant {
+	case "client-credentials":
+		form.Set("grant_type", "client_credentials")
+	default:
+		refresh := a.token.RefreshToken
+		if refresh == "" {
+			refresh = a.refreshToken
+		}
+		if refresh == "" && a.grant == "authorization-code" {
+			return ErrLoginRequired{Service: a.service}
+		}
+		if refresh == "" {
+			return ErrAuthSetting{Type: "oauth2", Setting: "auth-refresh-token"}
+		}
+
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refresh)
+	}
+
+	if a.scopes != "" {
+		form.Set("scope", a.scopes)
+	}
+
+	return a.requestToken(form)
+}
+
+// requestToken performs the token request and caches the result
+func (a *oauth2Auth) requestToken(form url.Values) error {
+	// public clients have no secret, so they always identify themselves in the body
+	if (a.clientAuth == "body" || a.clientSecret == "") && a.clientID != "" {
+		form.Set("client_id", a.clientID)
+		if a.clientSecret != "" {
+			form.Set("client_secret", a.clientSecret)
+		}
+	}
+
+	req, err := http.NewRequest("POST", a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if a.clientAuth != "body" && a.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	}
+
+	if a.verbose > 0 {
+		log.Printf("requesting oauth2 token from %s using %s\n", a.tokenURL, form.Get("grant_type"))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		AccessToken  string      `json:"access_token"`
+		TokenType    string      `json:"token_type"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		code := result.Error
+		if code == "" {
+			code = http.StatusText(resp.StatusCode)
+		}
+		return ErrOAuth2{Status: resp.StatusCode, Code: code, Description: result.ErrorDescription}
+	}
+
+	token := oauth2Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    result.TokenType,
+		RefreshToken: result.RefreshToken,
+	}
+
+	// refresh tokens are not always rotated, so keep the one we have
+	if token.RefreshToken == "" {
+		token.RefreshToken = a.token.RefreshToken
+	}
+
+	if seconds, err := result.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	a.token = token
+	return storeOAuth2Token(a.service, a.cacheKey(), token)
+}
+
+// cacheKey identifies the tokens issued to the client for the scopes
+func (a *oauth2Auth) cacheKey() string {
+	sum := sha256.Sum256([]byte(a.tokenURL + "\n" + a.clientID + "\n" + a.scopes))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// testDB replaces the global db with a temporary one
+func testDB(t *testing.T, service string) func() {
+	tmpfile, err := ioutil.TempFile("", "rest.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.DB, err = bolt.Open(tmpfile.Name(), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, services, err := db.Init(tx)
+		if err != nil {
+			return err
+		}
+
+		_, err = services.CreateBucket([]byte(service))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		db.Close()
+		os.Remove(tmpfile.Name())
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	defer testDB(t, "oauth")()
+
+	issued := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, secret, ok := req.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		if req.FormValue("grant_type") != "client_credentials" {
+			t.Errorf("unexpected grant %s", req.FormValue("grant_type"))
+		}
+
+		issued++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token%d", issued),
+			"token_type":   "bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	r := &Request{Service: "oauth", Settings: NewSettings()}
+	r.Settings.AuthType = sql.NullString{String: "oauth2", Valid: true}
+	r.Settings.AuthTokenURL = sql.NullString{String: server.URL, Valid: true}
+	r.Settings.AuthClientID = sql.NullString{String: "client", Valid: true}
+	r.Settings.AuthClientSecret = sql.NullString{String: "$CLIENT_SECRET", Valid: true}
+	os.Setenv("CLIENT_SECRET", "secret")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	auth, err := r.Auth(replacer(r.Settings.Parameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	if err := auth.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+	if h := req.Header.Get("Authorization"); h != "Bearer token1" {
+		t.Fatalf("unexpected authorization %s", h)
+	}
+
+	// a new provider uses the cached token
+	auth, err = r.Auth(replacer(r.Settings.Parameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+	if issued != 1 {
+		t.Fatalf("expected cached token to be used, %d tokens issued", issued)
+	}
+
+	// a rejected token is refreshed
+	again, err := auth.Challenge(req, &http.Response{StatusCode: http.StatusUnauthorized})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again {
+		t.Fatal("expected request to be retried after refresh")
+	}
+	if err := auth.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+	if h := req.Header.Get("Authorization"); h != "Bearer token2" {
+		t.Fatalf("unexpected authorization after refresh %s", h)
+	}
+
+	// an alias asking for other scopes gets its own token
+	r.Settings.AuthScopes = sql.NullString{String: "admin", Valid: true}
+	admin, err := r.Auth(replacer(r.Settings.Parameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+	if h := req.Header.Get("Authorization"); h != "Bearer token3" {
+		t.Fatalf("expected a token for the admin scope got %s", h)
+	}
+
+	// and the token for the other scopes is still cached
+	r.Settings.AuthScopes = sql.NullString{}
+	auth, err = r.Auth(replacer(r.Settings.Parameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+	if h := req.Header.Get("Authorization"); h != "Bearer token2" || issued != 3 {
+		t.Fatalf("expected the cached token got %s with %d tokens issued", h, issued)
+	}
+}
+
+func TestStoreOAuth2TokenAgain(t *testing.T) {
+	defer testDB(t, "oauth")()
+
+	// the cache key sorts just after the legacy access-token key
+	for _, token := range []string{"token1", "token2"} {
+		if err := storeOAuth2Token("oauth", "b0", oauth2Token{AccessToken: token}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cached, err := loadOAuth2Token("oauth", "b0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.AccessToken != "token2" {
+		t.Errorf("expected the new token got %s", cached.AccessToken)
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// openAPIFile is an OpenAPI or Swagger document to create the service from
+var openAPIFile string
+
+func init() {
+	initSrv.Flag("openapi", "create the service and an alias for every operation from an OpenAPI 3 or Swagger 2 document, importing it again updates the aliases").
+		StringVar(&openAPIFile)
+}
+
+// ErrOpenAPI is returned when the document can't be imported
+type ErrOpenAPI struct {
+	File   string
+	Reason string
+}
+
+func (e ErrOpenAPI) Error() string {
+	return fmt.Sprintf("can't import %s: %s", e.File, e.Reason)
+}
+
+// openAPIMethods are the operations of a path item in the order they are imported
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+// openAPIDocument is the part of an OpenAPI 3 or Swagger 2 document that
+// describes how to make requests, the schemas are ignored
+type openAPIDocument struct {
+	Swagger string `yaml:"swagger"`
+	OpenAPI string `yaml:"openapi"`
+
+	// openapi 3
+	Servers    []openAPIServer `yaml:"servers"`
+	Components struct {
+		Parameters      map[string]openAPIParameter      `yaml:"parameters"`
+		SecuritySchemes map[string]openAPISecurityScheme `yaml:"securitySchemes"`
+	} `yaml:"components"`
+
+	// swagger 2
+	Host                string                           `yaml:"host"`
+	BasePath            string                           `yaml:"basePath"`
+	Schemes             []string                         `yaml:"schemes"`
+	Parameters          map[string]openAPIParameter      `yaml:"parameters"`
+	SecurityDefinitions map[string]openAPISecurityScheme `yaml:"securityDefinitions"`
+
+	Paths    map[string]openAPIPathItem `yaml:"paths"`
+	Security []map[string][]string      `yaml:"security"`
+
+	// file is the absolute path of the document, and raw is all of it for
+	// validating against the schemas
+	file string
+	raw  map[string]interface{}
+}
+
+type openAPIServer struct {
+	URL       string `yaml:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+type openAPIPathItem struct {
+	Get     *openAPIOperation `yaml:"get"`
+	Put     *openAPIOperation `yaml:"put"`
+	Post    *openAPIOperation `yaml:"post"`
+	Delete  *openAPIOperation `yaml:"delete"`
+	Options *openAPIOperation `yaml:"options"`
+	Head    *openAPIOperation `yaml:"head"`
+	Patch   *openAPIOperation `yaml:"patch"`
+
+	Parameters []openAPIParameter `yaml:"parameters"`
+}
+
+type openAPIOperation struct {
+	OperationID string             `yaml:"operationId"`
+	Summary     string             `yaml:"summary"`
+	Description string             `yaml:"description"`
+	Parameters  []openAPIParameter `yaml:"parameters"`
+
+	// nil uses the document security, an empty list turns it off
+	Security *[]map[string][]string `yaml:"security"`
+}
+
+type openAPIParameter struct {
+	Ref      string `yaml:"$ref"`
+	Name     string `yaml:"name"`
+	In       string `yaml:"in"`
+	Required bool   `yaml:"required"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	Name   string `yaml:"name"`
+	In     string `yaml:"in"`
+}
+
+// loadOpenAPI reads the document, yaml and json are both accepted
+func loadOpenAPI(filename string) (*openAPIDocument, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, ErrOpenAPI{File: filename, Reason: err.Error()}
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") && !strings.HasPrefix(doc.Swagger, "2.") {
+		return nil, ErrOpenAPI{File: filename, Reason: "expected an openapi 3 or swagger 2 document"}
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(buf, &raw); err != nil {
+		return nil, ErrOpenAPI{File: filename, Reason: err.Error()}
+	}
+	doc.raw, _ = normalizeYAML(raw).(map[string]interface{})
+
+	doc.file, err = filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// importOpenAPI writes the service settings and aliases described by the
+// document.  Settings already stored for the service, or its aliases, are
+// kept so importing again doesn't undo what was changed by hand, while the
+// method, path, and description of an alias always follow the document.
+func importOpenAPI(sb *bolt.Bucket, doc *openAPIDocument) error {
+	spec, err := doc.settings()
+	if err != nil {
+		return err
+	}
+
+	s := defaultSettings.Clone()
+	s.Merge(spec)
+	s.Merge(LoadSettings(sb))
+	// the service is validated against the document it was last imported from
+	s.OpenAPISpec = sql.NullString{String: doc.file, Valid: true}
+	s.Merge(settings)
+	if err := s.Write(sb); err != nil {
+		return err
+	}
+
+	ab, err := sb.CreateBucketIfNotExists([]byte("aliases"))
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range doc.aliases() {
+		if err := alias.write(ab); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// settings for the service, from the first server and the security that
+// applies to every operation
+func (doc *openAPIDocument) settings() (Settings, error) {
+	s := NewSettings()
+
+	if len(doc.Servers) > 0 {
+		server := doc.Servers[0]
+		raw := server.URL
+		for name, variable := range server.Variables {
+			raw = strings.Replace(raw, "{"+name+"}", variable.Default, -1)
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			return s, err
+		}
+
+		// relative servers only give the base path
+		if u.Host != "" {
+			s.Scheme = sql.NullString{String: u.Scheme, Valid: true}
+			if err := setOpenAPIHost(&s, u.Host); err != nil {
+				return s, err
+			}
+		}
+		s.BasePath = sql.NullString{String: u.Path, Valid: true}
+	}
+
+	if doc.Host != "" {
+		s.Scheme = sql.NullString{String: "https", Valid: true}
+		// prefer https when the service supports it
+		if len(doc.Schemes) > 0 {
+			s.Scheme.String = doc.Schemes[0]
+			for _, scheme := range doc.Schemes {
+				if scheme == "https" {
+					s.Scheme.String = scheme
+				}
+			}
+		}
+
+		if err := setOpenAPIHost(&s, doc.Host); err != nil {
+			return s, err
+		}
+	}
+
+	if doc.BasePath != "" {
+		s.BasePath = sql.NullString{String: doc.BasePath, Valid: true}
+	}
+
+	if len(doc.Security) > 0 {
+		doc.applySecurity(&s, doc.Security[0])
+	}
+
+	return s, nil
+}
+
+// setOpenAPIHost sets the host and port, the port defaults to the one for the scheme
+func setOpenAPIHost(s *Settings, host string) error {
+	u := url.URL{Scheme: s.Scheme.String, Host: host}
+	s.Host = sql.NullString{String: u.Hostname(), Valid: true}
+
+	port := u.Port()
+	switch {
+	case port != "":
+	case s.Scheme.String == "http":
+		port = "80"
+	default:
+		port = "443"
+	}
+
+	p, err := strconv.ParseInt(port, 10, 64)
+	if err != nil {
+		return err
+	}
+	s.Port = sql.NullInt64{Int64: p, Valid: true}
+
+	return nil
+}
+
+// aliases for every operation that has an operation id
+func (doc *openAPIDocument) aliases() []importedAlias {
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	aliases := make([]importedAlias, 0)
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		item := doc.Paths[p]
+		operations := []*openAPIOperation{item.Get, item.Put, item.Post, item.Delete, item.Options, item.Head, item.Patch}
+
+		for i, op := range operations {
+			if op == nil {
+				continue
+			}
+
+			method := openAPIMethods[i]
+			switch {
+			case op.OperationID == "":
+				log.Printf("skipping %s %s, it has no operationId\n", strings.ToUpper(method), p)
+				continue
+			case seen[op.OperationID]:
+				log.Printf("skipping %s %s, operationId %s is already used\n", strings.ToUpper(method), p, op.OperationID)
+				continue
+			case reservedCommand(op.OperationID):
+				log.Printf("skipping %s %s, operationId %s is a rest command\n", strings.ToUpper(method), p, op.OperationID)
+				continue
+			}
+			seen[op.OperationID] = true
+
+			aliases = append(aliases, doc.alias(method, p, item, op))
+		}
+	}
+
+	return aliases
+}
+
+// reservedCommand reports if the name is already a command, aliases of the
+// current service don't count as they are about to be replaced
+func reservedCommand(name string) bool {
+	if _, ok := aliasParams[name]; ok {
+		return false
+	}
+
+	return kingpin.CommandLine.GetCommand(name) != nil
+}
+
+func (doc *openAPIDocument) alias(method, p string, item openAPIPathItem, op *openAPIOperation) importedAlias {
+	a := importedAlias{
+		Name:        op.OperationID,
+		Method:      method,
+		Path:        strings.TrimPrefix(openAPIPath(p), "/"),
+		Description: op.Summary,
+		Settings:    NewSettings(),
+	}
+
+	if a.Description == "" {
+		a.Description = strings.TrimSpace(strings.SplitN(op.Description, "\n", 2)[0])
+	}
+
+	// required query and header parameters are always sent, so they become
+	// placeholders, operation parameters replace those of the path
+	params := make(map[string]openAPIParameter)
+	for _, param := range append(item.Parameters, op.Parameters...) {
+		param = doc.resolve(param)
+		params[param.In+" "+param.Name] = param
+	}
+
+	for _, param := range params {
+		if !param.Required {
+			continue
+		}
+
+		switch param.In {
+		case "query":
+			a.Settings.Queries[param.Name] = openAPIPlaceholder(param.Name)
+		case "header":
+			a.Settings.Headers[param.Name] = openAPIPlaceholder(param.Name)
+		}
+	}
+
+	// only security that differs from the service needs alias settings
+	if op.Security != nil && len(*op.Security) > 0 {
+		requirement := (*op.Security)[0]
+		if len(doc.Security) == 0 || !sameRequirement(requirement, doc.Security[0]) {
+			doc.applySecurity(&a.Settings, requirement)
+		}
+	}
+
+	return a
+}
+
+// resolve a local reference to a shared parameter
+func (doc *openAPIDocument) resolve(param openAPIParameter) openAPIParameter {
+	switch {
+	case strings.HasPrefix(param.Ref, "#/components/parameters/"):
+		return doc.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+	case strings.HasPrefix(param.Ref, "#/parameters/"):
+		return doc.Parameters[strings.TrimPrefix(param.Ref, "#/parameters/")]
+	}
+
+	return param
+}
+
+// applySecurity sets the headers, queries, or basic auth that the security
+// schemes need.  The credentials are placeholders, so they can be stored as
+// parameters of the service.
+func (doc *openAPIDocument) applySecurity(s *Settings, requirement map[string][]string) {
+	names := make([]string, 0, len(requirement))
+	for name := range requirement {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		scheme, ok := doc.Components.SecuritySchemes[name]
+		if !ok {
+			scheme, ok = doc.SecurityDefinitions[name]
+		}
+		if !ok {
+			log.Printf("skipping unknown security scheme %s\n", name)
+			continue
+		}
+
+		switch {
+		case scheme.Type == "apiKey" && scheme.In == "query":
+			s.Queries[scheme.Name] = openAPIPlaceholder(name)
+		case scheme.Type == "apiKey" && scheme.In == "cookie":
+			s.Headers["Cookie"] = scheme.Name + "=" + openAPIPlaceholder(name)
+		case scheme.Type == "apiKey":
+			s.Headers[scheme.Name] = openAPIPlaceholder(name)
+		case scheme.Type == "basic", scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+			s.Username = sql.NullString{String: ":username", Valid: true}
+			s.Password = sql.NullString{String: ":password", Valid: true}
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "digest"):
+			s.AuthType = sql.NullString{String: "digest", Valid: true}
+			s.Username = sql.NullString{String: ":username", Valid: true}
+			s.Password = sql.NullString{String: ":password", Valid: true}
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"),
+			scheme.Type == "oauth2", scheme.Type == "openIdConnect":
+			s.Headers["Authorization"] = "Bearer :token"
+		default:
+			log.Printf("skipping security scheme %s, %s %s isn't supported\n", name, scheme.Type, scheme.Scheme)
+		}
+	}
+}
+
+func sameRequirement(a, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+var openAPIPathParam = regexp.MustCompile(`{([^{}]+)}`)
+
+// openAPIPath converts the {param} templates of the path into placeholders
+func openAPIPath(p string) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range openAPIPathParam.FindAllStringSubmatchIndex(p, -1) {
+		b.WriteString(p[last:match[0]])
+
+		placeholder := openAPIPlaceholder(p[match[2]:match[3]])
+		// a colon placeholder would swallow the text following it
+		if match[1] < len(p) && isWordChar(p[match[1]]) {
+			placeholder = "{{" + openAPIParamName(p[match[2]:match[3]]) + "}}"
+		}
+		b.WriteString(placeholder)
+
+		last = match[1]
+	}
+	b.WriteString(p[last:])
+
+	return b.String()
+}
+
+// openAPIPlaceholder is the placeholder for the parameter, names that can't
+// be a colon placeholder use brackets
+func openAPIPlaceholder(name string) string {
+	name = openAPIParamName(name)
+	if strings.Contains(name, "-") {
+		return "{{" + name + "}}"
+	}
+	return ":" + name
+}
+
+// openAPIParamName replaces the characters a parameter name can't have
+func openAPIParamName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 128 && (isWordChar(byte(r)) || r == '-') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
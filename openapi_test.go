@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const testSwagger = `
+swagger: "2.0"
+host: api.example.com:8080
+basePath: /v2
+schemes: [http, https]
+securityDefinitions:
+  key: {type: apiKey, in: query, name: api_key}
+  basic: {type: basic}
+security:
+  - key: []
+parameters:
+  id: {name: id, in: path, required: true}
+paths:
+  /users/{id}:
+    get:
+      operationId: getUser
+      summary: Get a user
+      parameters:
+        - $ref: '#/parameters/id'
+        - {name: X-Tenant, in: header, required: true}
+        - {name: expand, in: query}
+    put:
+      operationId: updateUser
+      security:
+        - basic: []
+  /users/{id}/avatar.{format}:
+    get:
+      operationId: getAvatar
+`
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := map[string]string{
+		"/users":                    "/users",
+		"/users/{id}":               "/users/:id",
+		"/users/{user-id}/posts":    "/users/{{user-id}}/posts",
+		"/files/{name}.json":        "/files/:name.json",
+		"/v{version}api/{a.b}":      "/v{{version}}api/:a_b",
+		"/users/{id}/posts/{post}/": "/users/:id/posts/:post/",
+	}
+
+	for p, expected := range tests {
+		if converted := openAPIPath(p); converted != expected {
+			t.Errorf("%s: expected %s got %s", p, expected, converted)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal([]byte(testSwagger), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := doc.settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Scheme.String != "https" || s.Host.String != "api.example.com" || s.Port.Int64 != 8080 || s.BasePath.String != "/v2" {
+		t.Errorf("unexpected service %s://%s:%d%s", s.Scheme.String, s.Host.String, s.Port.Int64, s.BasePath.String)
+	}
+	if s.Queries["api_key"] != ":key" {
+		t.Errorf("expected the api key query, got %v", s.Queries)
+	}
+
+	aliases := doc.aliases()
+	if len(aliases) != 3 {
+		t.Fatalf("expected 3 aliases got %d", len(aliases))
+	}
+
+	get, update, avatar := aliases[0], aliases[1], aliases[2]
+	if get.Name != "getUser" || get.Method != "get" || get.Path != "users/:id" || get.Description != "Get a user" {
+		t.Errorf("unexpected alias %+v", get)
+	}
+	if get.Settings.Headers["X-Tenant"] != "{{X-Tenant}}" || len(get.Settings.Queries) != 0 {
+		t.Errorf("expected only the required parameters, got %v %v", get.Settings.Headers, get.Settings.Queries)
+	}
+	if update.Settings.Username.String != ":username" || update.Settings.Password.String != ":password" {
+		t.Errorf("expected basic auth for %s", update.Name)
+	}
+	if avatar.Path != "users/:id/avatar.:format" {
+		t.Errorf("unexpected path %s", avatar.Path)
+	}
+}
+
+func TestImportOpenAPI(t *testing.T) {
+	defer testDB(t, "openapi")()
+
+	var doc openAPIDocument
+	if err := yaml.Unmarshal([]byte(testSwagger), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	importDoc := func() {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return importOpenAPI(getBucket(tx, "services.openapi"), &doc)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	importDoc()
+
+	// change the service and alias by hand, and the document
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := getBucket(tx, "services.openapi").Put([]byte("host"), []byte("staging.example.com")); err != nil {
+			return err
+		}
+		return getBucket(tx, "services.openapi.aliases.getUser.headers").Put([]byte("X-Tenant"), []byte("acme"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc.Paths["/users/{id}"].Get.Summary = "Fetch a user"
+	importDoc()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		s := LoadSettings(getBucket(tx, "services.openapi"))
+		if s.Host.String != "staging.example.com" || s.Port.Int64 != 8080 || s.Retries != defaultSettings.Retries {
+			t.Errorf("unexpected service settings %s:%d retries %d", s.Host.String, s.Port.Int64, s.Retries.Int64)
+		}
+
+		a := getBucket(tx, "services.openapi.aliases.getUser")
+		if desc := string(a.Get([]byte("description"))); desc != "Fetch a user" {
+			t.Errorf("expected the description to be updated, got %s", desc)
+		}
+		if tenant := LoadSettings(a).Headers["X-Tenant"]; tenant != "acme" {
+			t.Errorf("expected the edited header to be kept, got %s", tenant)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// how often download progress is logged
+const progressInterval = time.Second
+
+// stream the response body straight to the output file, the body is never
+// held in memory so hooks, filters, and set parameters can't be applied
+func (r *Response) stream() error {
+	path, err := homedir.Expand(os.ExpandEnv(r.OutputFile))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	r.skipped("it was written to " + path)
+
+	var w io.Writer = f
+	var p *progress
+	if r.verbose > 0 {
+		p = &progress{total: r.resp.ContentLength, last: time.Now()}
+		w = io.MultiWriter(f, p)
+	}
+
+	n, err := io.Copy(w, r.resp.Body)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if p != nil {
+		log.Printf("wrote %s to %s\n", byteSize(n), path)
+	}
+
+	// there is nothing left to display
+	r.streamed = true
+
+	return nil
+}
+
+// skipped explains which response processing was not done and why
+func (r *Response) skipped(reason string) {
+	var skip []string
+	if r.ResponseHook != "" {
+		skip = append(skip, "response hook")
+	}
+	if r.Filter != "" {
+		skip = append(skip, "filter")
+	}
+	if len(r.SetParameters) > 0 {
+		skip = append(skip, "set parameters")
+	}
+
+	if len(skip) > 0 {
+		log.Printf("skipping %s on the response body as %s\n", strings.Join(skip, ", "), reason)
+	}
+}
+
+// progress logs the amount downloaded as it is written
+type progress struct {
+	total   int64
+	written int64
+	last    time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+
+	if time.Since(p.last) >= progressInterval {
+		p.last = time.Now()
+		if p.total > 0 {
+			log.Printf("downloaded %s of %s (%d%%)\n", byteSize(p.written), byteSize(p.total), p.written*100/p.total)
+		} else {
+			log.Printf("downloaded %s\n", byteSize(p.written))
+		}
+	}
+
+	return len(b), nil
+}
+
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isBinary reports if the response body should not be treated as text.  The
+// content type is used when it is given, otherwise the body is sniffed.
+func isBinary(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+yaml"):
+		return false
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "application/yaml", "application/x-yaml",
+		"application/x-ndjson", "application/graphql":
+		return false
+	case "application/octet-stream":
+		// servers often use this as a default, so check what was actually sent
+		return http.DetectContentType(body) != "text/plain; charset=utf-8"
+	}
+
+	return true
+}
+
+// isTerminal reports if the file is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		binary      bool
+	}{
+		{"application/json", `{"a": 1}`, false},
+		{"application/problem+json; charset=utf-8", `{}`, false},
+		{"text/csv", "a,b", false},
+		{"image/png", "\x89PNG\r\n\x1a\n", true},
+		{"application/zip", "PK", true},
+		{"application/octet-stream", "plain text", false},
+		{"application/octet-stream", "\x00\x01\x02", true},
+		{"", "plain text", false},
+		{"", "\x89PNG\r\n\x1a\n", true},
+	}
+
+	for _, test := range tests {
+		if result := isBinary(test.contentType, []byte(test.body)); result != test.binary {
+			t.Errorf("%s %q: expected binary %t", test.contentType, test.body, test.binary)
+		}
+	}
+}
+
+func TestStreamOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "out.bin")
+	body := strings.Repeat("x", 1<<16)
+
+	r := Response{
+		OutputFile: path,
+		resp: &http.Response{
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		},
+		verbose: 1,
+	}
+
+	if err := r.stream(); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != body {
+		t.Errorf("expected %d bytes to be written, got %d", len(body), len(written))
+	}
+	if r.Raw != nil {
+		t.Error("streamed body should not be buffered")
+	}
+
+	var out bytes.Buffer
+	if err := r.Display(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing to be displayed got %q", out.String())
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	jmespath "github.com/jmespath/go-jmespath"
+)
+
+type ErrPaginateType struct {
+	Type string
+}
+
+func (e ErrPaginateType) Error() string {
+	return fmt.Sprintf("unknown pagination type %s, expected one of none, link, cursor, page, or offset", e.Type)
+}
+
+type ErrPaginateSetting struct {
+	Type    string
+	Setting string
+}
+
+func (e ErrPaginateSetting) Error() string {
+	return fmt.Sprintf("%s pagination requires %s to be set", e.Type, e.Setting)
+}
+
+// ErrPageItems is returned when a page doesn't contain a list of items to merge
+type ErrPageItems struct {
+	Page int
+}
+
+func (e ErrPageItems) Error() string {
+	return fmt.Sprintf("page %d is not a json array, use --paginate-items to select the items in the page", e.Page)
+}
+
+// ErrPageOrigin is returned when the next page is on another host and the
+// request has credentials, following it would send them to that host
+type ErrPageOrigin struct {
+	URL string
+}
+
+func (e ErrPageOrigin) Error() string {
+	return fmt.Sprintf("not following the next page %s, it is on another host and would be sent the credentials", e.URL)
+}
+
+// pager tracks where we are in the list
+type pager struct {
+	kind     string
+	items    string
+	cursor   string
+	param    string
+	number   int64
+	size     int64
+	maxPages int
+}
+
+func (s Settings) pager() (*pager, error) {
+	p := &pager{
+		kind:     s.PaginateType.String,
+		items:    s.PaginateItems.String,
+		cursor:   s.PaginateCursor.String,
+		param:    s.PaginateParam.String,
+		number:   s.PaginateStart.Int64,
+		size:     s.PaginateSize.Int64,
+		maxPages: int(s.PaginateMaxPages.Int64),
+	}
+
+	if !s.PaginateMaxPages.Valid {
+		p.maxPages = int(defaultSettings.PaginateMaxPages.Int64)
+	}
+
+	switch p.kind {
+	case "", "none":
+		return nil, nil
+	case "link":
+	case "cursor":
+		if p.cursor == "" {
+			return nil, ErrPaginateSetting{Type: "cursor", Setting: "paginate-cursor"}
+		}
+	case "page":
+		if !s.PaginateStart.Valid {
+			p.number = 1
+		}
+	case "offset":
+	default:
+		return nil, ErrPaginateType{Type: p.kind}
+	}
+
+	if p.param == "" {
+		p.param = p.kind
+	}
+
+	return p, nil
+}
+
+// Paginate fetches the following pages of a list response one after the
+// other, each through the retry logic.  The items from every page are merged
+// into a single json array so filters and hooks see the whole list.  A page
+// that fails is returned as is so the error can be displayed.
+func (r *Request) Paginate(resp *http.Response) (*http.Response, error) {
+	p, err := r.Settings.pager()
+	if err != nil || p == nil {
+		return resp, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, nil
+	}
+
+	items := []interface{}{}
+	current := resp
+	for page := 1; ; page++ {
+		body, err := ioutil.ReadAll(current.Body)
+		current.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		pageItems, next, err := p.next(page, current, body)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, pageItems...)
+
+		if next == nil {
+			break
+		}
+
+		if page >= p.maxPages {
+			log.Printf("stopping after %d pages, use --max-pages to fetch more\n", page)
+			break
+		}
+
+		if r.verbose > 0 {
+			log.Printf("fetching page %d: %s\n", page+1, next)
+		}
+
+		if !sameOrigin(r.req.URL, next) && r.hasCredentials() {
+			return nil, ErrPageOrigin{URL: next.String()}
+		}
+
+		req := r.req.Clone(context.Background())
+		req.URL = next
+		req.Host = ""
+		if _, err := rewind(req); err != nil {
+			return nil, err
+		}
+
+		current, err = r.send(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if current.StatusCode < 200 || current.StatusCode > 299 {
+			log.Printf("page %d failed with %s\n", page+1, current.Status)
+			return current, nil
+		}
+	}
+
+	merged, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+
+	return &http.Response{
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(merged)),
+		ContentLength: int64(len(merged)),
+		Request:       resp.Request,
+	}, nil
+}
+
+// first sets the page or offset of the first request, unless it is already
+// in the query, so the service starts where the following pages continue from
+func (p *pager) first(u *url.URL) {
+	if p.kind != "page" && p.kind != "offset" {
+		return
+	}
+
+	q := u.Query()
+	if _, ok := q[p.param]; ok {
+		return
+	}
+
+	q.Set(p.param, strconv.FormatInt(p.number, 10))
+	u.RawQuery = q.Encode()
+}
+
+// hasCredentials reports if the request is authenticated, signed, or has a
+// header that looks like it holds a credential
+func (r *Request) hasCredentials() bool {
+	if r.Settings.authType() != "none" || r.signer != nil {
+		return true
+	}
+
+	for key := range r.req.Header {
+		if sensitive(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// next returns the items in the page, and the url of the following page or
+// nil if this is the last page
+func (p *pager) next(page int, resp *http.Response, body []byte) ([]interface{}, *url.URL, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	// keep large ids intact
+	dec.UseNumber()
+
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, nil, err
+	}
+
+	found := data
+	if p.items != "" {
+		var err error
+		found, err = jmespath.Search(p.items, data)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	items, ok := found.([]interface{})
+	if !ok && found != nil {
+		return nil, nil, ErrPageItems{Page: page}
+	}
+
+	current := resp.Request.URL
+
+	switch p.kind {
+	case "link":
+		link := nextLink(resp.Header)
+		if link == "" {
+			return items, nil, nil
+		}
+
+		next, err := current.Parse(link)
+		return items, next, err
+
+	case "cursor":
+		cursor, err := jmespath.Search(p.cursor, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cursor == nil || cursor == "" || cursor == false {
+			return items, nil, nil
+		}
+
+		return items, withQuery(current, p.param, fmt.Sprint(cursor)), nil
+
+	default:
+		// a short or empty page is the last one
+		if len(items) == 0 || (p.size > 0 && int64(len(items)) < p.size) {
+			return items, nil, nil
+		}
+
+		// continue from the page or offset that was asked for
+		if n, err := strconv.ParseInt(current.Query().Get(p.param), 10, 64); err == nil {
+			p.number = n
+		}
+
+		switch {
+		case p.kind == "page":
+			p.number++
+		case p.size > 0:
+			p.number += p.size
+		default:
+			p.number += int64(len(items))
+		}
+
+		return items, withQuery(current, p.param, strconv.FormatInt(p.number, 10)), nil
+	}
+}
+
+func withQuery(u *url.URL, key, value string) *url.URL {
+	next := *u
+	q := next.Query()
+	q.Set(key, value)
+	next.RawQuery = q.Encode()
+	return &next
+}
+
+// nextLink finds the rel="next" target in RFC 5988 Link headers
+func nextLink(header http.Header) string {
+	for _, value := range header["Link"] {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				key, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(key, "rel") {
+					continue
+				}
+
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(r, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/link":
+			page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			if page < 3 {
+				w.Header().Set("Link", fmt.Sprintf(`</link?page=%d>; rel="next", </link?page=3>; rel="last"`, page+1))
+			}
+			fmt.Fprintf(w, `[%d]`, page)
+
+		case "/cursor":
+			switch req.URL.Query().Get("after") {
+			case "":
+				fmt.Fprint(w, `{"data": [1, 2], "next": "b"}`)
+			case "b":
+				fmt.Fprint(w, `{"data": [3], "next": null}`)
+			}
+
+		// the first page or offset is always asked for
+		case "/page":
+			switch req.URL.Query().Get("page") {
+			case "1":
+				fmt.Fprint(w, `[1, 2]`)
+			case "2":
+				fmt.Fprint(w, `[3, 4]`)
+			case "3":
+				fmt.Fprint(w, `[]`)
+			default:
+				fmt.Fprint(w, `"missing page"`)
+			}
+
+		case "/offset":
+			switch req.URL.Query().Get("offset") {
+			case "0":
+				fmt.Fprint(w, `[1, 2]`)
+			case "2":
+				fmt.Fprint(w, `[3]`)
+			default:
+				fmt.Fprint(w, `"missing offset"`)
+			}
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path     string
+		kind     string
+		items    string
+		cursor   string
+		param    string
+		size     int64
+		maxPages int64
+		expected string
+	}{
+		{path: "/link", kind: "link", expected: "[1,2,3]"},
+		{path: "/link", kind: "link", maxPages: 2, expected: "[1,2]"},
+		{path: "/cursor", kind: "cursor", items: "data", cursor: "next", param: "after", expected: "[1,2,3]"},
+		{path: "/page", kind: "page", expected: "[1,2,3,4]"},
+		{path: "/page?page=2", kind: "page", expected: "[3,4]"},
+		{path: "/offset", kind: "offset", size: 2, expected: "[1,2,3]"},
+	}
+
+	for _, test := range tests {
+		r := Request{Settings: NewSettings()}
+		r.Settings.PaginateType = sql.NullString{String: test.kind, Valid: true}
+		r.Settings.PaginateItems = sql.NullString{String: test.items, Valid: test.items != ""}
+		r.Settings.PaginateCursor = sql.NullString{String: test.cursor, Valid: test.cursor != ""}
+		r.Settings.PaginateParam = sql.NullString{String: test.param, Valid: test.param != ""}
+		r.Settings.PaginateSize = sql.NullInt64{Int64: test.size, Valid: test.size > 0}
+		r.Settings.PaginateMaxPages = sql.NullInt64{Int64: test.maxPages, Valid: test.maxPages > 0}
+
+		p, err := r.Settings.pager()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ := url.Parse(server.URL + test.path)
+		p.first(u)
+
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.req = req
+
+		resp, err := r.retry(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err = r.Paginate(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		if string(body) != test.expected {
+			t.Errorf("%s %s: expected %s got %s", test.kind, test.path, test.expected, body)
+		}
+	}
+}
+
+func TestPaginateOtherOrigin(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `[2]`)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/list?page=2>; rel="next"`, other.URL))
+		fmt.Fprint(w, `[1]`)
+	}))
+	defer server.Close()
+
+	paginate := func(token string) (*http.Response, error) {
+		r := Request{Settings: NewSettings()}
+		r.Settings.PaginateType = sql.NullString{String: "link", Valid: true}
+		if token != "" {
+			r.Settings.AuthType = sql.NullString{String: "bearer", Valid: true}
+			r.Settings.AuthToken = sql.NullString{String: token, Valid: true}
+		}
+
+		req, err := http.NewRequest("GET", server.URL+"/list", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.req = req
+
+		resp, err := r.retry(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r.Paginate(resp)
+	}
+
+	// without credentials there is nothing to leak
+	resp, err := paginate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "[1,2]" {
+		t.Errorf("expected both pages got %s", body)
+	}
+
+	if _, err := paginate("secret"); err == nil {
+		t.Error("expected the next page on another host not to be followed with credentials")
+	} else if _, ok := err.(ErrPageOrigin); !ok {
+		t.Errorf("expected ErrPageOrigin got %v", err)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://api.github.com/repositories/1/stargazers?page=1>; rel="prev", <https://api.github.com/repositories/1/stargazers?page=3>; rel="next"`)
+
+	if link := nextLink(header); link != "https://api.github.com/repositories/1/stargazers?page=3" {
+		t.Errorf("unexpected next link %s", link)
+	}
+
+	if link := nextLink(http.Header{}); link != "" {
+		t.Errorf("expected no next link, got %s", link)
+	}
+}
@@ -0,0 +1,881 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// postmanSchema is the collection format that is exported
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+var (
+	postmanImport string
+	postmanExport bool
+)
+
+func init() {
+	impSrv.Arg("service", "service to import into, it is created if it doesn't exist").
+		Required().
+		StringVar(&request.Service)
+	impSrv.Flag("postman", "postman collection to import, requests become aliases and variables become parameters").
+		Required().
+		StringVar(&postmanImport)
+
+	expSrv.Flag("postman", "export the service and its aliases as a postman collection").
+		Required().
+		BoolVar(&postmanExport)
+}
+
+// ErrPostman is returned when the collection can't be imported
+type ErrPostman struct {
+	File   string
+	Reason string
+}
+
+func (e ErrPostman) Error() string {
+	return fmt.Sprintf("can't import %s: %s", e.File, e.Reason)
+}
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanKeyValue `json:"variable,omitempty"`
+	Auth     *postmanAuth      `json:"auth,omitempty"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// postmanItem is either a folder of items or a request
+type postmanItem struct {
+	Name        string             `json:"name"`
+	Description postmanDescription `json:"description,omitempty"`
+	Item        []postmanItem      `json:"item,omitempty"`
+	Request     *postmanRequest    `json:"request,omitempty"`
+	Auth        *postmanAuth       `json:"auth,omitempty"`
+}
+
+type postmanRequest struct {
+	Method      string             `json:"method"`
+	Header      []postmanKeyValue  `json:"header"`
+	URL         postmanURL         `json:"url"`
+	Body        *postmanBody       `json:"body,omitempty"`
+	Auth        *postmanAuth       `json:"auth,omitempty"`
+	Description postmanDescription `json:"description,omitempty"`
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Host     interface{}       `json:"host,omitempty"`
+	Path     interface{}       `json:"path,omitempty"`
+	Query    []postmanKeyValue `json:"query,omitempty"`
+	Variable []postmanKeyValue `json:"variable,omitempty"`
+}
+
+// UnmarshalJSON accepts urls given as only a string
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Raw); err == nil {
+		return nil
+	}
+
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw,omitempty"`
+	URLEncoded []postmanKeyValue `json:"urlencoded,omitempty"`
+	FormData   []postmanKeyValue `json:"formdata,omitempty"`
+	File       *postmanFile      `json:"file,omitempty"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql,omitempty"`
+	Options *struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options,omitempty"`
+}
+
+type postmanFile struct {
+	Src string `json:"src"`
+}
+
+type postmanKeyValue struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type,omitempty"`
+	Src      interface{} `json:"src,omitempty"`
+	Disabled bool        `json:"disabled,omitempty"`
+}
+
+// value is the value as a string, variables can have any json type
+func (kv postmanKeyValue) value() string {
+	switch v := kv.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+
+	buf, _ := json.Marshal(kv.Value)
+	return string(buf)
+}
+
+// postmanDescription is a string, or an object with the content
+type postmanDescription string
+
+func (d *postmanDescription) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*d = postmanDescription(s)
+		return nil
+	}
+
+	var object struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*d = postmanDescription(object.Content)
+	return nil
+}
+
+// postmanAuth is the auth type along with its settings, which are a list of
+// key values in v2.1 collections and an object in v2.0
+type postmanAuth struct {
+	Type   string
+	Params map[string]string
+}
+
+func (a *postmanAuth) UnmarshalJSON(data []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(object["type"], &a.Type); err != nil {
+		return err
+	}
+
+	a.Params = make(map[string]string)
+	params, ok := object[a.Type]
+	if !ok {
+		return nil
+	}
+
+	var list []postmanKeyValue
+	if err := json.Unmarshal(params, &list); err == nil {
+		for _, kv := range list {
+			a.Params[kv.Key] = kv.value()
+		}
+		return nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(params, &values); err != nil {
+		return err
+	}
+	for key, value := range values {
+		a.Params[key] = postmanKeyValue{Value: value}.value()
+	}
+	return nil
+}
+
+func (a postmanAuth) MarshalJSON() ([]byte, error) {
+	object := map[string]interface{}{"type": a.Type}
+
+	if len(a.Params) > 0 {
+		params := make([]postmanKeyValue, 0, len(a.Params))
+		for _, key := range sortedKeys(a.Params) {
+			params = append(params, postmanKeyValue{Key: key, Value: a.Params[key], Type: "string"})
+		}
+		object[a.Type] = params
+	}
+
+	return json.Marshal(object)
+}
+
+// postmanGrants are the rest oauth2 grants for postman grant types
+var postmanGrants = map[string]string{
+	"client_credentials": "client-credentials",
+	"authorization_code": "authorization-code",
+}
+
+// apply the auth to the settings, it returns false for unsupported types
+func (a postmanAuth) apply(s *Settings) bool {
+	set := func(setting *sql.NullString, param string) {
+		if value := a.Params[param]; value != "" {
+			*setting = sql.NullString{String: value, Valid: true}
+		}
+	}
+
+	authType := a.Type
+	switch a.Type {
+	case "noauth":
+		authType = "none"
+	case "bearer":
+		set(&s.AuthToken, "token")
+	case "basic", "digest":
+		set(&s.Username, "username")
+		set(&s.Password, "password")
+	case "apikey":
+		authType = "api-key"
+		set(&s.AuthKeyName, "key")
+		set(&s.AuthKey, "value")
+		set(&s.AuthKeyIn, "in")
+	case "oauth2":
+		set(&s.AuthTokenURL, "accessTokenUrl")
+		set(&s.AuthURL, "authUrl")
+		set(&s.AuthClientID, "clientId")
+		set(&s.AuthClientSecret, "clientSecret")
+		set(&s.AuthScopes, "scope")
+		set(&s.AuthRedirectURL, "redirect_uri")
+
+		// the authorization code grant is set once logged in
+		grant := postmanGrants[a.Params["grant_type"]]
+		if grant == "authorization-code" {
+			return true
+		}
+		if grant != "" {
+			s.AuthGrant = sql.NullString{String: grant, Valid: true}
+		}
+	default:
+		return false
+	}
+
+	s.AuthType = sql.NullString{String: authType, Valid: true}
+	return true
+}
+
+// postmanAuthFrom is the postman auth for the settings, or nil if there is
+// no auth type set
+func postmanAuthFrom(s Settings) *postmanAuth {
+	params := make(map[string]string)
+	set := func(param string, setting sql.NullString) {
+		if setting.String != "" {
+			params[param] = setting.String
+		}
+	}
+
+	t := s.AuthType.String
+	switch t {
+	case "":
+		if s.Username.String == "" || s.Password.String == "" {
+			return nil
+		}
+		t = "basic"
+		fallthrough
+	case "basic", "digest":
+		set("username", s.Username)
+		set("password", s.Password)
+	case "none":
+		t = "noauth"
+	case "bearer":
+		set("token", s.AuthToken)
+	case "api-key":
+		t = "apikey"
+		set("key", s.AuthKeyName)
+		set("value", s.AuthKey)
+		set("in", s.AuthKeyIn)
+	case "oauth2":
+		set("accessTokenUrl", s.AuthTokenURL)
+		set("authUrl", s.AuthURL)
+		set("clientId", s.AuthClientID)
+		set("clientSecret", s.AuthClientSecret)
+		set("scope", s.AuthScopes)
+		set("redirect_uri", s.AuthRedirectURL)
+		for grant, restGrant := range postmanGrants {
+			if restGrant == s.AuthGrant.String {
+				params["grant_type"] = grant
+			}
+		}
+	default:
+		log.Printf("not exporting %s auth, postman doesn't support it\n", t)
+		return nil
+	}
+
+	return &postmanAuth{Type: t, Params: params}
+}
+
+// loadPostman reads the collection
+func loadPostman(filename string) (*postmanCollection, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var c postmanCollection
+	if err := json.Unmarshal(buf, &c); err != nil {
+		return nil, ErrPostman{File: filename, Reason: err.Error()}
+	}
+
+	if !strings.Contains(c.Info.Schema, "/collection/v2") {
+		return nil, ErrPostman{File: filename, Reason: "expected a postman v2 collection"}
+	}
+
+	return &c, nil
+}
+
+// ImportPostman creates or updates the service from the collection.  Like
+// openapi imports the settings already stored are kept.
+func ImportPostman() error {
+	c, err := loadPostman(postmanImport)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		sb, err := request.MakeServiceBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := importPostman(sb, c); err != nil {
+			return err
+		}
+
+		return initInfo(tx, request.Service)
+	})
+}
+
+func importPostman(sb *bolt.Bucket, c *postmanCollection) error {
+	vars := make(map[string]string)
+	for _, kv := range c.Variable {
+		if !kv.Disabled && kv.Key != "" {
+			vars[kv.Key] = kv.value()
+		}
+	}
+
+	im := &postmanImporter{vars: vars, names: make(map[string]bool), baseVars: make(map[string]bool)}
+	im.walk(c.Item, nil, nil)
+
+	// the variables that held the service url aren't needed as parameters
+	spec := NewSettings()
+	for key, value := range vars {
+		if !im.baseVars[key] {
+			spec.Parameters[key] = value
+		}
+	}
+
+	if c.Auth != nil && !c.Auth.apply(&spec) {
+		log.Printf("skipping collection auth, %s isn't supported\n", c.Auth.Type)
+	}
+
+	if im.base != nil {
+		spec.Scheme = sql.NullString{String: im.base.Scheme, Valid: true}
+		spec.Host = sql.NullString{String: im.base.Hostname(), Valid: true}
+		spec.Port = sql.NullInt64{Int64: im.port, Valid: true}
+		spec.BasePath = sql.NullString{String: im.base.Path, Valid: true}
+	}
+
+	s := defaultSettings.Clone()
+	s.Merge(spec)
+	s.Merge(LoadSettings(sb))
+	if err := s.Write(sb); err != nil {
+		return err
+	}
+
+	ab, err := sb.CreateBucketIfNotExists([]byte("aliases"))
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range im.aliases {
+		if err := alias.write(ab); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// postmanImporter turns the requests of a collection into aliases, the
+// first request with a known host decides the service url
+type postmanImporter struct {
+	vars     map[string]string
+	names    map[string]bool
+	baseVars map[string]bool
+	base     *url.URL
+	port     int64
+	aliases  []importedAlias
+}
+
+// walk the items, folder names become part of the alias names and folder
+// auth applies to the requests in the folder
+func (im *postmanImporter) walk(items []postmanItem, folders []string, auth *postmanAuth) {
+	for _, item := range items {
+		itemAuth := auth
+		if item.Auth != nil && item.Auth.Type != "inherit" {
+			itemAuth = item.Auth
+		}
+
+		names := append(append([]string{}, folders...), item.Name)
+		if item.Request == nil {
+			im.walk(item.Item, names, itemAuth)
+			continue
+		}
+
+		if item.Request.Auth != nil && item.Request.Auth.Type != "inherit" {
+			itemAuth = item.Request.Auth
+		}
+
+		description := item.Request.Description
+		if description == "" {
+			description = item.Description
+		}
+		if description == "" {
+			description = postmanDescription(strings.Join(names, " / "))
+		}
+
+		alias, ok := im.alias(names, *item.Request, itemAuth)
+		if !ok {
+			continue
+		}
+		alias.Description = strings.TrimSpace(strings.SplitN(string(description), "\n", 2)[0])
+		im.aliases = append(im.aliases, alias)
+	}
+}
+
+func (im *postmanImporter) alias(names []string, req postmanRequest, auth *postmanAuth) (importedAlias, bool) {
+	name := im.name(names)
+	if reservedCommand(name) {
+		log.Printf("skipping %s, %s is a rest command\n", strings.Join(names, " / "), name)
+		return importedAlias{}, false
+	}
+
+	p, ok := im.path(req.URL)
+	if !ok {
+		log.Printf("skipping %s, it has no url\n", strings.Join(names, " / "))
+		return importedAlias{}, false
+	}
+
+	a := importedAlias{
+		Name:     name,
+		Method:   strings.ToLower(req.Method),
+		Path:     p,
+		Settings: NewSettings(),
+	}
+	if a.Method == "" {
+		a.Method = "get"
+	}
+
+	for _, kv := range postmanQuery(req.URL) {
+		a.Settings.Queries[kv.Key] = kv.value()
+	}
+
+	// path variables are the same :param placeholders, their values are
+	// stored with the alias
+	for _, kv := range req.URL.Variable {
+		if value := kv.value(); value != "" {
+			a.Settings.Parameters[kv.Key] = value
+		}
+	}
+
+	// empty values would be placeholders for nothing
+	for _, kv := range req.Header {
+		if !kv.Disabled && kv.Key != "" && kv.value() != "" {
+			a.Settings.Headers[kv.Key] = kv.value()
+		}
+	}
+
+	if req.Body != nil {
+		im.body(&a, *req.Body)
+	}
+
+	if auth != nil && !auth.apply(&a.Settings) {
+		log.Printf("skipping auth of %s, %s isn't supported\n", name, auth.Type)
+	}
+
+	return a, true
+}
+
+// name is the folders and request name as a single command name
+func (im *postmanImporter) name(names []string) string {
+	words := regexp.MustCompile(`[^[:alnum:]]+`)
+	name := strings.Trim(strings.ToLower(words.ReplaceAllString(strings.Join(names, " "), "-")), "-")
+	if name == "" {
+		name = "request"
+	}
+
+	unique := name
+	for i := 2; im.names[unique]; i++ {
+		unique = name + "-" + strconv.Itoa(i)
+	}
+	im.names[unique] = true
+
+	return unique
+}
+
+// path splits the url into the part that identifies the service and the
+// path of the alias, which is relative to the service base path
+func (im *postmanImporter) path(u postmanURL) (string, bool) {
+	raw := u.Raw
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	if raw == "" {
+		return "", false
+	}
+
+	// the service is either a variable such as {{baseUrl}}, or the scheme and host
+	prefix := raw
+	switch {
+	case strings.HasPrefix(raw, "{{") && strings.Contains(raw, "}}"):
+		prefix = raw[:strings.Index(raw, "}}")+2]
+	case strings.Contains(raw, "://"):
+		scheme, rest, _ := strings.Cut(raw, "://")
+		host, _, _ := strings.Cut(rest, "/")
+		prefix = scheme + "://" + host
+	default:
+		prefix, _, _ = strings.Cut(raw, "/")
+	}
+	rest := strings.TrimPrefix(raw, prefix)
+
+	expanded := strings.NewReplacer(postmanVars(im.vars)...).Replace(prefix)
+	if !strings.Contains(expanded, "://") {
+		expanded = "http://" + expanded
+	}
+
+	base, err := url.Parse(expanded)
+	if err != nil || strings.Contains(expanded, "{{") || base.Host == "" {
+		log.Printf("can't tell the service url from %s, set the host with 'rest service set'\n", prefix)
+		return strings.Trim(rest, "/"), true
+	}
+
+	port := int64(443)
+	if base.Scheme == "http" {
+		port = 80
+	}
+	if p, err := strconv.ParseInt(base.Port(), 10, 64); err == nil {
+		port = p
+	}
+
+	if im.base == nil {
+		im.base, im.port = base, port
+	}
+	if strings.HasPrefix(prefix, "{{") {
+		im.baseVars[strings.Trim(prefix, "{}")] = true
+	}
+
+	if base.Scheme != im.base.Scheme || base.Host != im.base.Host || port != im.port {
+		log.Printf("%s isn't on %s, the alias will use the service url\n", u.Raw, im.base.Host)
+	}
+
+	full := path.Join("/", base.Path, rest)
+	basePath := path.Join("/", im.base.Path)
+	if basePath != "/" && full != basePath && !strings.HasPrefix(full, basePath+"/") {
+		log.Printf("%s isn't under the base path %s\n", u.Raw, basePath)
+		return strings.TrimPrefix(full, "/"), true
+	}
+
+	return strings.Trim(strings.TrimPrefix(full, basePath), "/"), true
+}
+
+// postmanVars replaces collection variables with their values
+func postmanVars(vars map[string]string) []string {
+	rep := make([]string, 0, len(vars)*2)
+	for key, value := range vars {
+		rep = append(rep, "{{"+key+"}}", value)
+	}
+	return rep
+}
+
+// postmanQuery is the enabled query items, older collections only have
+// them in the raw url
+func postmanQuery(u postmanURL) []postmanKeyValue {
+	query := make([]postmanKeyValue, 0)
+	if len(u.Query) > 0 {
+		for _, kv := range u.Query {
+			if !kv.Disabled && kv.Key != "" && kv.value() != "" {
+				query = append(query, kv)
+			}
+		}
+		return query
+	}
+
+	_, raw, _ := strings.Cut(u.Raw, "?")
+	raw, _, _ = strings.Cut(raw, "#")
+	for _, item := range strings.Split(raw, "&") {
+		key, value, _ := strings.Cut(item, "=")
+		if key != "" && value != "" {
+			query = append(query, postmanKeyValue{Key: key, Value: value})
+		}
+	}
+	return query
+}
+
+// body sets the alias data, or form, from the request body
+func (im *postmanImporter) body(a *importedAlias, body postmanBody) {
+	switch body.Mode {
+	case "raw":
+		// a leading @ would read a file
+		a.Data = body.Raw
+		if strings.HasPrefix(a.Data, "@") {
+			a.Data = "@" + a.Data
+		}
+
+		if body.Options != nil && !hasHeader(a.Settings.Headers, "Content-Type") {
+			switch body.Options.Raw.Language {
+			case "json":
+				a.Settings.Headers["Content-Type"] = "application/json"
+			case "xml":
+				a.Settings.Headers["Content-Type"] = "application/xml"
+			}
+		}
+
+	case "urlencoded":
+		for _, kv := range body.URLEncoded {
+			if !kv.Disabled && kv.Key != "" {
+				a.Settings.Form[kv.Key] = kv.value()
+			}
+		}
+
+	case "formdata":
+		for _, kv := range body.FormData {
+			switch {
+			case kv.Disabled || kv.Key == "":
+			case kv.Type == "file":
+				src := kv.Src
+				if list, ok := src.([]interface{}); ok && len(list) > 0 {
+					src = list[0]
+				}
+				if src, ok := src.(string); ok && src != "" {
+					a.Settings.Files[kv.Key] = src
+				}
+			default:
+				a.Settings.Form[kv.Key] = kv.value()
+			}
+		}
+
+	case "file":
+		if body.File != nil && body.File.Src != "" {
+			a.Data = "@" + body.File.Src
+		}
+
+	case "graphql":
+		if body.GraphQL == nil {
+			break
+		}
+
+		query := map[string]interface{}{"query": body.GraphQL.Query}
+		var variables interface{}
+		if err := json.Unmarshal([]byte(body.GraphQL.Variables), &variables); err == nil {
+			query["variables"] = variables
+		}
+
+		buf, _ := json.Marshal(query)
+		a.Data = string(buf)
+		if !hasHeader(a.Settings.Headers, "Content-Type") {
+			a.Settings.Headers["Content-Type"] = "application/json"
+		}
+	}
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportPostman writes the service and its aliases as a postman collection
+func ExportPostman(w io.Writer) error {
+	var c *postmanCollection
+	err := db.View(func(tx *bolt.Tx) error {
+		sb, err := request.ServiceBucket(tx)
+		if err != nil {
+			return err
+		}
+
+		c, err = exportPostman(request.Service, sb)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(buf))
+	return err
+}
+
+func exportPostman(name string, sb *bolt.Bucket) (*postmanCollection, error) {
+	s := LoadSettings(sb)
+
+	base := s.URL()
+	if port := s.Port.Int64; s.Scheme.String == "https" && port == 443 || s.Scheme.String == "http" && port == 80 {
+		base.Host = s.Host.String
+	}
+	base.Path = path.Join("/", s.BasePath.String)
+
+	c := &postmanCollection{
+		Info:     postmanInfo{Name: name, Schema: postmanSchema},
+		Item:     make([]postmanItem, 0),
+		Variable: []postmanKeyValue{{Key: "baseUrl", Value: strings.TrimSuffix(base.String(), "/")}},
+		Auth:     postmanAuthFrom(s),
+	}
+
+	for _, key := range sortedKeys(s.Parameters) {
+		if key == "baseUrl" {
+			log.Println("not exporting the baseUrl parameter, it is the service url")
+			continue
+		}
+		c.Variable = append(c.Variable, postmanKeyValue{Key: key, Value: s.Parameters[key]})
+	}
+
+	ab := sb.Bucket([]byte("aliases"))
+	if ab == nil {
+		return c, nil
+	}
+
+	err := ab.ForEach(func(k, _ []byte) error {
+		a := ab.Bucket(k)
+		if a == nil {
+			return nil
+		}
+
+		c.Item = append(c.Item, exportAlias(string(k), a, s))
+		return nil
+	})
+
+	return c, err
+}
+
+// exportAlias makes a postman request from the alias, the service headers
+// and queries are added to every request
+func exportAlias(name string, a *bolt.Bucket, service Settings) postmanItem {
+	s := LoadSettings(a)
+	p := string(a.Get([]byte("path")))
+	data := storedAliasData(a)
+
+	// colon placeholders are converted to postman variables
+	known := make(map[string]bool)
+	for _, params := range []map[string]string{service.Parameters, s.Parameters} {
+		for param := range params {
+			known[param] = true
+		}
+	}
+	for param := range findParams(p) {
+		known[param] = true
+	}
+
+	req := postmanRequest{
+		Method: strings.ToUpper(string(a.Get([]byte("method")))),
+		Header: make([]postmanKeyValue, 0),
+		Auth:   postmanAuthFrom(s),
+	}
+
+	headers := service.Clone().Headers
+	mergeMap(headers, s.Headers)
+	for _, key := range sortedKeys(headers) {
+		req.Header = append(req.Header, postmanKeyValue{Key: key, Value: postmanPlaceholders(headers[key], known)})
+	}
+
+	queries := service.Clone().Queries
+	mergeMap(queries, s.Queries)
+
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	raw := "{{baseUrl}}/" + strings.Trim(p, "/")
+	req.URL = postmanURL{Host: []string{"{{baseUrl}}"}, Path: segments}
+
+	rawQuery := make([]string, 0, len(queries))
+	for _, key := range sortedKeys(queries) {
+		value := postmanPlaceholders(queries[key], known)
+		req.URL.Query = append(req.URL.Query, postmanKeyValue{Key: key, Value: value})
+		rawQuery = append(rawQuery, key+"="+value)
+	}
+	if len(rawQuery) > 0 {
+		raw += "?" + strings.Join(rawQuery, "&")
+	}
+	req.URL.Raw = raw
+
+	// path variables refer to the collection variable of the same name
+	for _, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+
+		param := strings.TrimPrefix(segment, ":")
+		value := s.Parameters[param]
+		if _, ok := service.Parameters[param]; ok && value == "" {
+			value = "{{" + param + "}}"
+		}
+		req.URL.Variable = append(req.URL.Variable, postmanKeyValue{Key: param, Value: value})
+	}
+
+	switch {
+	case len(s.Files) > 0:
+		req.Body = &postmanBody{Mode: "formdata"}
+		for _, key := range sortedKeys(s.Form) {
+			req.Body.FormData = append(req.Body.FormData, postmanKeyValue{Key: key, Value: s.Form[key], Type: "text"})
+		}
+		for _, key := range sortedKeys(s.Files) {
+			req.Body.FormData = append(req.Body.FormData, postmanKeyValue{Key: key, Src: s.Files[key], Type: "file"})
+		}
+	case len(s.Form) > 0:
+		req.Body = &postmanBody{Mode: "urlencoded"}
+		for _, key := range sortedKeys(s.Form) {
+			req.Body.URLEncoded = append(req.Body.URLEncoded, postmanKeyValue{Key: key, Value: s.Form[key]})
+		}
+	case strings.HasPrefix(data, "@@"):
+		req.Body = &postmanBody{Mode: "raw", Raw: postmanPlaceholders(strings.TrimPrefix(data, "@"), known)}
+	case strings.HasPrefix(data, "@") && data != "@":
+		req.Body = &postmanBody{Mode: "file", File: &postmanFile{Src: strings.TrimPrefix(data, "@")}}
+	case data != "":
+		req.Body = &postmanBody{Mode: "raw", Raw: postmanPlaceholders(data, known)}
+	}
+
+	item := postmanItem{Name: name, Request: &req}
+	if description := a.Get([]byte("description")); len(description) > 0 {
+		item.Request.Description = postmanDescription(description)
+	}
+
+	return item
+}
+
+var colonParam = regexp.MustCompile(`:([[:alpha:]_][[:word:]]*)`)
+
+// postmanPlaceholders converts :param placeholders to {{param}}.  To leave
+// text such as {"a":true} alone, only known parameters and placeholders at
+// the start or after a space are converted.
+func postmanPlaceholders(s string, known map[string]bool) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range colonParam.FindAllStringSubmatchIndex(s, -1) {
+		name := s[match[2]:match[3]]
+		start := match[0] == 0 || strings.ContainsAny(s[match[0]-1:match[0]], " \t\n")
+		if !known[name] && !start {
+			continue
+		}
+
+		b.WriteString(s[last:match[0]])
+		b.WriteString("{{" + name + "}}")
+		last = match[1]
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+const testCollection = `{
+	"info": {"name": "pets", "schema": "https://schema.getpostman.com/json/collection/v2.0.0/collection.json"},
+	"variable": [
+		{"key": "baseUrl", "value": "https://api.example.com:8443/v1"},
+		{"key": "token", "value": "secret"},
+		{"key": "page", "value": 2}
+	],
+	"auth": {"type": "apikey", "apikey": {"key": "X-Key", "value": "{{token}}", "in": "header"}},
+	"item": [
+		{"name": "Pets", "item": [
+			{"name": "Get pet", "request": {
+				"method": "GET",
+				"header": [{"key": "Accept", "value": "application/json"}, {"key": "X-Debug", "value": "1", "disabled": true}],
+				"url": {
+					"raw": "{{baseUrl}}/pets/:id?page={{page}}&debug=1",
+					"query": [{"key": "page", "value": "{{page}}"}, {"key": "debug", "value": "1", "disabled": true}],
+					"variable": [{"key": "id", "value": "7"}]
+				},
+				"description": {"content": "Fetch a pet\nin detail"}
+			}},
+			{"name": "Add pet", "request": {
+				"method": "POST",
+				"url": "{{baseUrl}}/pets",
+				"body": {"mode": "raw", "raw": "@{\"name\": \"{{name}}\"}", "options": {"raw": {"language": "json"}}},
+				"auth": {"type": "basic", "basic": [{"key": "username", "value": "admin"}, {"key": "password", "value": "pw"}]}
+			}}
+		]},
+		{"name": "Add pet", "request": {
+			"method": "POST",
+			"url": "https://api.example.com:8443/v1/forms",
+			"body": {"mode": "urlencoded", "urlencoded": [{"key": "name", "value": "rex"}]}
+		}}
+	]
+}`
+
+func TestImportPostman(t *testing.T) {
+	defer testDB(t, "postman")()
+
+	var c postmanCollection
+	if err := json.Unmarshal([]byte(testCollection), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		return importPostman(getBucket(tx, "services.postman"), &c)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		s := LoadSettings(getBucket(tx, "services.postman"))
+		if u := s.URL(); u.String() != "https://api.example.com:8443" || s.BasePath.String != "/v1" {
+			t.Errorf("unexpected service url %s%s", u.String(), s.BasePath.String)
+		}
+		if _, ok := s.Parameters["baseUrl"]; ok || s.Parameters["token"] != "secret" || s.Parameters["page"] != "2" {
+			t.Errorf("unexpected parameters %v", s.Parameters)
+		}
+		if s.AuthType.String != "api-key" || s.AuthKeyName.String != "X-Key" || s.AuthKey.String != "{{token}}" {
+			t.Errorf("unexpected auth %s %s %s", s.AuthType.String, s.AuthKeyName.String, s.AuthKey.String)
+		}
+
+		get := getBucket(tx, "services.postman.aliases.pets-get-pet")
+		if get == nil {
+			t.Fatal("expected the pets-get-pet alias")
+		}
+		if path := string(get.Get([]byte("path"))); path != "pets/:id" {
+			t.Errorf("unexpected path %s", path)
+		}
+		if desc := string(get.Get([]byte("description"))); desc != "Fetch a pet" {
+			t.Errorf("unexpected description %s", desc)
+		}
+		gs := LoadSettings(get)
+		if len(gs.Queries) != 1 || gs.Queries["page"] != "{{page}}" || len(gs.Headers) != 1 || gs.Parameters["id"] != "7" {
+			t.Errorf("unexpected alias settings %v %v %v", gs.Queries, gs.Headers, gs.Parameters)
+		}
+
+		add := getBucket(tx, "services.postman.aliases.pets-add-pet")
+		if data := string(add.Get([]byte("data"))); data != `@{"name": "{{name}}"}` {
+			t.Errorf("unexpected data %s", data)
+		}
+		as := LoadSettings(add)
+		if as.AuthType.String != "basic" || as.Username.String != "admin" || as.Headers["Content-Type"] != "application/json" {
+			t.Errorf("unexpected alias auth %s %s %v", as.AuthType.String, as.Username.String, as.Headers)
+		}
+
+		form := getBucket(tx, "services.postman.aliases.add-pet")
+		if path := string(form.Get([]byte("path"))); path != "forms" || LoadSettings(form).Form["name"] != "rex" {
+			t.Errorf("unexpected form alias %s %v", path, LoadSettings(form).Form)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exporting and importing again gives the same aliases
+	var exported *postmanCollection
+	err = db.Update(func(tx *bolt.Tx) error {
+		var err error
+		exported, err = exportPostman("postman", getBucket(tx, "services.postman"))
+		if err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(exported)
+		if err != nil {
+			return err
+		}
+
+		var c postmanCollection
+		if err := json.Unmarshal(buf, &c); err != nil {
+			return err
+		}
+
+		sb, err := getBucket(tx, "services").CreateBucket([]byte("copy"))
+		if err != nil {
+			return err
+		}
+		return importPostman(sb, &c)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		original := LoadSettings(getBucket(tx, "services.postman"))
+		copied := LoadSettings(getBucket(tx, "services.copy"))
+		if u := copied.URL(); u != original.URL() || copied.BasePath != original.BasePath || copied.AuthKey != original.AuthKey {
+			t.Errorf("service changed on export %s%s", u.String(), copied.BasePath.String)
+		}
+
+		for _, alias := range []string{"pets-get-pet", "pets-add-pet", "add-pet"} {
+			a := getBucket(tx, "services.postman.aliases."+alias)
+			b := getBucket(tx, "services.copy.aliases."+alias)
+			if b == nil {
+				t.Errorf("%s wasn't exported", alias)
+				continue
+			}
+
+			for _, key := range []string{"method", "path", "data"} {
+				if string(a.Get([]byte(key))) != string(b.Get([]byte(key))) {
+					t.Errorf("%s %s changed from %s to %s", alias, key, a.Get([]byte(key)), b.Get([]byte(key)))
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostmanPlaceholders(t *testing.T) {
+	known := map[string]bool{"id": true}
+	tests := map[string]string{
+		"Bearer :token":              "Bearer {{token}}",
+		":token":                     "{{token}}",
+		`{"id"::id, "a":true}`:       `{"id":{{id}}, "a":true}`,
+		"10:30 https://example.com/": "10:30 https://example.com/",
+		"name:asc":                   "name:asc",
+	}
+
+	for s, expected := range tests {
+		if converted := postmanPlaceholders(s, known); converted != expected {
+			t.Errorf("%s: expected %s got %s", s, expected, converted)
+		}
+	}
+}
@@ -1,15 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"math"
-	"math/rand"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"os"
 	"path"
 	"strings"
 	"time"
@@ -22,11 +21,19 @@ type Request struct {
 	Method  string
 	Path    string
 	Data    string
-
-	Settings  Settings
+	Edit    bool
+	Form    map[string]string
+	Files   map[string]string
+
+	Settings Settings
+	// Flags are the settings given on the command line, they override
+	// the stored settings
+	Flags     Settings
 	NoQueries bool
 	NoHeaders bool
 	DryRun    bool
+	// As prints the request as a command instead of performing it
+	As string
 
 	RequestDataHook string
 	RequestHook     string
@@ -35,13 +42,96 @@ type Request struct {
 
 	URL url.URL
 
-	req *http.Request
+	req    *http.Request
+	auth   AuthProvider
+	signer Signer
+	lua    *luaEnv
+
+	// keyHeader is the headers that identify the request in the cache,
+	// without any credentials
+	keyHeader http.Header
+	// credentials identifies who the request is made as in the cache
+	credentials string
+	timings     []*attemptTiming
+	// contract is the openapi operation the request matched, if validating
+	contract *contract
 
 	verbose int
 }
 
+// ErrRequest adds what was being done when performing the request failed
+type ErrRequest struct {
+	Context string
+	Err     error
+}
+
+func (e ErrRequest) Error() string {
+	return fmt.Sprintf("error %s: %s", e.Context, e.Err)
+}
+
+// newRequest copies the request parsed from the command line along with the
+// setting flags, so it can be performed without changing the parsed values
+func newRequest() *Request {
+	r := request
+	r.Flags = settings.Clone()
+	r.verbose = verbLevel
+	return &r
+}
+
+// Execute loads the request data, performs the request, and loads the
+// response.  Requests don't share any state so many can be executed at once.
+func (r *Request) Execute(resp *Response) error {
+	env := &luaEnv{}
+	defer env.Close()
+	r.lua = env
+	resp.lua = env
+
+	resp.verbose = r.verbose
+	resp.reconnect = r.Reconnect
+
+	if err := r.LoadData(); err != nil {
+		return ErrRequest{Context: "loading request data", Err: err}
+	}
+
+	start := time.Now()
+	httpResp, err := r.Perform()
+	if err == ErrDryRun {
+		return err
+	}
+	if err != nil {
+		if httpResp != nil {
+			httpResp.Body.Close()
+		}
+		r.record(nil, nil, err, start)
+		return ErrRequest{Context: "making request", Err: err}
+	}
+
+	httpResp, err = r.Paginate(httpResp)
+	if err != nil {
+		r.record(nil, nil, err, start)
+		return ErrRequest{Context: "fetching pages", Err: err}
+	}
+
+	resp.contract = r.contract
+	resp.service = r.Service
+	if p, _ := r.Settings.pager(); p != nil && resp.contract != nil {
+		resp.contract.skipBody = true
+	}
+
+	if err := resp.Load(httpResp, r.Settings); err != nil {
+		r.record(httpResp, resp, err, start)
+		return ErrRequest{Context: "displaying result", Err: err}
+	}
+
+	r.record(httpResp, resp, nil, start)
+	return nil
+}
+
+// ErrDryRun is returned instead of a response when the request was only displayed
+var ErrDryRun = errors.New("dry run, the request was not sent")
+
 func (r *Request) Perform() (*http.Response, error) {
-	if err := db.Update(request.LoadSettings); err != nil {
+	if err := db.Update(r.LoadSettings); err != nil {
 		return nil, err
 	}
 
@@ -50,18 +140,36 @@ func (r *Request) Perform() (*http.Response, error) {
 		return nil, err
 	}
 
+	// signing happens after everything else has been added to the request
+	if err := r.sign(req); err != nil {
+		return nil, err
+	}
+
+	if socket := r.Settings.socket(); socket != "" && (r.DryRun || r.verbose > 0) {
+		log.Println("connecting through unix socket", socket)
+	}
+
+	if r.As == "curl" {
+		command, err := r.curlCommand(req)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println(command)
+		return nil, ErrDryRun
+	}
+
 	if r.DryRun {
 		dump, err := httputil.DumpRequestOut(req, true)
 		if err != nil {
 			return nil, err
 		}
 		fmt.Println(string(dump))
-		os.Exit(0)
+		return nil, ErrDryRun
 	}
 
 	switch r.verbose {
 	case 1:
-		log.Println(request.URL.String())
+		log.Println(r.URL.String())
 	case 2, 3:
 		// at level 3 display the raw request
 		extra := false
@@ -78,15 +186,44 @@ func (r *Request) Perform() (*http.Response, error) {
 		log.Println(string(dump))
 	}
 
-	return r.retry(req)
+	r.req = req
+	return r.send(req)
+}
+
+// Reconnect sends the request again to resume an event stream from the last
+// event that was received
+func (r *Request) Reconnect(lastEventID string) (*http.Response, error) {
+	if _, err := rewind(r.req); err != nil {
+		return nil, err
+	}
+
+	if lastEventID != "" {
+		r.req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	if r.auth != nil {
+		if err := r.auth.Apply(r.req); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.retry(r.req)
 }
 
 func (r *Request) retry(req *http.Request) (*http.Response, error) {
-	client := &http.Client{}
+	client, err := r.Settings.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := r.timingFormat()
+	if err != nil {
+		return nil, err
+	}
 
 	var resp *http.Response
-	var err error
 	maxAttempts := int(r.Settings.Retries.Int64) + 1
+	challenged := false
 
 	for i := 0; i < maxAttempts; i++ {
 
@@ -94,23 +231,94 @@ func (r *Request) retry(req *http.Request) (*http.Response, error) {
 			log.Printf("attempt %d: %s %s\n", i, req.Method, req.URL)
 		}
 
-		resp, err = client.Do(req)
-		if err == nil && resp.StatusCode < 500 {
-			return resp, nil
+		// the previous attempt consumed the body, so send a fresh copy
+		if i > 0 || challenged {
+			resent, err := rewind(req)
+			if err != nil {
+				return nil, err
+			}
+
+			if r.verbose > 0 && resent {
+				log.Printf("attempt %d: resending %d byte body\n", i, req.ContentLength)
+			}
+
+			if r.auth != nil {
+				if err := r.auth.Apply(req); err != nil {
+					return nil, err
+				}
+			}
 		}
 
-		// TODO: respect the Retry-After header
-		delay := r.Settings.RetryDelay.Duration
+		limited, err := r.limit()
+		if err != nil {
+			return nil, err
+		}
 
-		if r.Settings.ExponentialBackoff.Bool {
-			delay *= time.Duration(math.Exp(float64(i)))
+		// sign every attempt so the signature timestamp is fresh
+		if err := r.sign(req); err != nil {
+			return nil, err
 		}
 
-		if r.Settings.RetryJitter.Bool {
-			delay = time.Duration(rand.Intn(int(delay)))
+		// every attempt is timed for the history, it is only shown when asked for
+		timing := newAttemptTiming(i, req, format)
+		timing.RateLimit = ms(limited)
+		r.timings = append(r.timings, timing)
+
+		resp, err = client.Do(timing.trace(req))
+		timing.received(resp, err)
+
+		// answering an authentication challenge doesn't count as an attempt
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && r.auth != nil && !challenged {
+			challenged = true
+			again, err := r.auth.Challenge(req, resp)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+
+			if again {
+				if r.verbose > 0 {
+					log.Printf("attempt %d: answering authentication challenge\n", i)
+				}
+				resp.Body.Close()
+				i--
+				continue
+			}
 		}
 
-		if r.verbose > 0 && delay > 0 && i < maxAttempts {
+		retry, rerr := r.Settings.shouldRetry(req, resp, err)
+		if rerr != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			timing.finish()
+			return nil, rerr
+		}
+
+		if !retry || i == maxAttempts-1 {
+			// a response finishes its timing once the body has been read
+			if resp == nil {
+				timing.finish()
+			}
+			break
+		}
+
+		delay := r.Settings.backoff(i)
+
+		// the server knows best when we can try again
+		if after, ok := retryAfter(resp, time.Now()); ok {
+			delay = r.Settings.retryWait(after)
+		}
+
+		timing.RetryWait = ms(delay)
+
+		// we are done with this response, so don't leak the connection
+		if resp != nil {
+			resp.Body.Close()
+		}
+		timing.finish()
+
+		if r.verbose > 0 && delay > 0 {
 			log.Printf("waiting %s to retry\n", delay)
 		}
 		<-time.After(delay)
@@ -135,6 +343,16 @@ func (r *Request) Prepare() (*http.Request, error) {
 	}
 	r.Data = replace(r.Data)
 
+	r.Form = make(map[string]string)
+	for key, value := range r.Settings.Form {
+		r.Form[key] = replace(value)
+	}
+
+	r.Files = make(map[string]string)
+	for key, value := range r.Settings.Files {
+		r.Files[key] = replace(value)
+	}
+
 	if !r.NoQueries {
 		q := r.URL.Query()
 		for key, value := range r.Settings.Queries {
@@ -146,13 +364,30 @@ func (r *Request) Prepare() (*http.Request, error) {
 		r.URL.RawQuery = q.Encode()
 	}
 
+	pager, err := r.Settings.pager()
+	if err != nil {
+		return nil, err
+	}
+	if pager != nil {
+		pager.first(&r.URL)
+	}
+
 	if err := r.hook(); err != nil {
 		return nil, err
 	}
 
 	// don't send the body if it's empty
 	var data io.Reader
-	if r.Data != "" {
+	contentType := ""
+	switch {
+	case r.hasForm():
+		body, ct, err := r.formBody()
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.NewReader(body)
+		contentType = ct
+	case r.Data != "":
 		data = strings.NewReader(r.Data)
 	}
 
@@ -166,20 +401,64 @@ func (r *Request) Prepare() (*http.Request, error) {
 		return nil, err
 	}
 
-	if r.Settings.Username.Valid && r.Settings.Password.Valid &&
-		r.Settings.Username.String != "" && r.Settings.Password.String != "" {
-		req.SetBasicAuth(replace(r.Settings.Username.String), replace(r.Settings.Password.String))
+	r.auth, err = r.Auth(replace)
+	if err != nil {
+		return nil, err
 	}
+	r.credentials = r.Settings.credentials(replace)
 
+	r.keyHeader = make(http.Header)
 	if !r.NoHeaders {
 		for key, value := range r.Settings.Headers {
 			v := replace(value)
 			if v[0] != ':' {
 				req.Header.Set(key, v)
+				r.keyHeader.Set(key, v)
 			}
 		}
 	}
 
+	// the form encoding decides the content type, not the stored headers
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+		r.keyHeader.Set("Content-Type", contentType)
+	}
+
+	// offline requests are served from the cache, fetching a token could
+	// need the network
+	if !r.Settings.Offline.Bool {
+		if err := r.auth.Apply(req); err != nil {
+			return nil, err
+		}
+
+		// the credentials would silently replace a stored header
+		for key := range r.keyHeader {
+			if req.Header.Get(key) != r.keyHeader.Get(key) {
+				return nil, ErrAuthConflict{Header: key, First: "a stored header", Second: r.Settings.authType() + " auth"}
+			}
+		}
+	}
+
+	r.signer, err = r.Settings.Signer(replace)
+	if err != nil {
+		return nil, err
+	}
+
+	// signing happens last, so the signature would replace the header
+	if r.signer != nil && req.Header.Get(r.signer.Header()) != "" {
+		first := r.Settings.authType() + " auth"
+		if _, ok := r.keyHeader[http.CanonicalHeaderKey(r.signer.Header())]; ok {
+			first = "a stored header"
+		}
+		return nil, ErrAuthConflict{Header: r.signer.Header(), First: first, Second: r.Settings.SigningType.String + " signing"}
+	}
+
+	// validated last so the hooks have already changed the request
+	r.contract, err = r.validateRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
 	return req, nil
 }
 
@@ -291,7 +570,7 @@ func (r Request) MakeMethodBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
 
 // LoadSettings from the database
 func (r *Request) LoadSettings(tx *bolt.Tx) error {
-	sb, pb, mb, err := request.Match(tx)
+	sb, pb, mb, err := r.Match(tx)
 	if err != nil {
 		return err
 	}
@@ -315,7 +594,10 @@ func (r *Request) LoadSettings(tx *bolt.Tx) error {
 	}
 
 	// load provided cli flags settings
-	r.Settings.Merge(settings)
+	r.Settings.Merge(r.Flags)
+
+	// a service imported from yaml may only have the socket
+	r.Settings.socketDefaults()
 
 	return nil
 }
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httputil"
+	"os"
 	"strings"
 
 	"github.com/boltdb/bolt"
@@ -18,27 +20,36 @@ type Response struct {
 	Raw     []byte
 	display []byte
 
-	resp *http.Response
+	resp     *http.Response
+	settings Settings
+	// service the request was made to, parameters are set in it
+	service string
+
+	// out is where streamed events are written, defaults to stdout
+	out io.Writer
+	lua *luaEnv
+	// contract checks the response against the openapi spec
+	contract *contract
+	// reconnect resumes an event stream from the last event id
+	reconnect func(lastEventID string) (*http.Response, error)
 
 	ResponseHook  string
 	Filter        string
 	Pretty        bool
 	PrettyIndent  string
 	SetParameters map[string]string
+	OutputFile    string
 
 	verbose int
 
-	ranHook bool
+	ranHook  bool
+	binary   bool
+	streamed bool
 }
 
 func (r *Response) Load(resp *http.Response, s Settings) error {
 	r.resp = resp
-
-	r.ResponseHook = s.ResponseHook.String
-	r.Pretty = s.Pretty.Bool
-	r.PrettyIndent = s.PrettyIndent.String
-	r.Filter = s.Filter.String
-	r.SetParameters = s.SetParameters
+	r.apply(s)
 
 	switch r.verbose {
 	case 1:
@@ -50,6 +61,11 @@ func (r *Response) Load(resp *http.Response, s Settings) error {
 			extra = true
 		}
 
+		// dumping the body would read all of it, which never ends for streams
+		if r.OutputFile != "" || streamKind(resp) != "" {
+			extra = false
+		}
+
 		dump, err := httputil.DumpResponse(r.resp, extra)
 		if err != nil {
 			// this is only the verbose logging, so carry on in case of error
@@ -60,11 +76,39 @@ func (r *Response) Load(resp *http.Response, s Settings) error {
 	}
 
 	defer resp.Body.Close()
+
+	// only the status of saved and streamed responses is validated
+	if r.OutputFile != "" || streamKind(resp) != "" {
+		if err := r.validate(nil); err != nil {
+			return err
+		}
+	}
+
+	if r.OutputFile != "" {
+		return r.stream()
+	}
+
+	if kind := streamKind(resp); kind != "" {
+		return r.follow(kind)
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
 	r.Raw = body
+
+	if err := r.validate(body); err != nil {
+		return err
+	}
+	r.binary = isBinary(resp.Header.Get("Content-Type"), body)
+
+	if r.binary && isTerminal(os.Stdout) {
+		log.Printf("not displaying %d byte binary response, use --output to save it to a file\n", len(body))
+		r.skipped("it is binary")
+		return nil
+	}
+
 	if err := r.Prepare(); err != nil {
 		return err
 	}
@@ -72,10 +116,48 @@ func (r *Response) Load(resp *http.Response, s Settings) error {
 	return nil
 }
 
+// validate the response against the openapi spec, when the request was
+// matched to an operation
+func (r *Response) validate(body []byte) error {
+	if r.contract == nil {
+		return nil
+	}
+	return r.contract.validateResponse(r.resp, body)
+}
+
+// apply the output settings to the response
+func (r *Response) apply(s Settings) {
+	r.settings = s
+
+	r.ResponseHook = s.ResponseHook.String
+	r.Pretty = s.Pretty.Bool
+	r.PrettyIndent = s.PrettyIndent.String
+	r.Filter = s.Filter.String
+	r.SetParameters = s.SetParameters
+	r.OutputFile = s.OutputFile.String
+}
+
 func (r Response) String() string {
 	return fmt.Sprint(string(r.display))
 }
 
+// Display writes the response to w, binary responses are written as is
+func (r Response) Display(w io.Writer) error {
+	// streamed events have already been displayed, and streamed files
+	// aren't displayed at all
+	if r.streamed {
+		return nil
+	}
+
+	if r.binary {
+		_, err := w.Write(r.display)
+		return err
+	}
+
+	_, err := fmt.Fprintln(w, r)
+	return err
+}
+
 func (r *Response) Prepare() error {
 	r.display = r.Raw
 	if err := r.hook(); err != nil {
@@ -87,7 +169,7 @@ func (r *Response) Prepare() error {
 		if err := r.filter(); err != nil {
 			return err
 		}
-	case r.Pretty:
+	case r.Pretty && !r.binary:
 		var msg json.RawMessage
 		err := json.Unmarshal(r.display, &msg)
 		if err != nil {
@@ -124,10 +206,18 @@ func (r *Response) filter() error {
 }
 
 func (r *Response) setParameters() error {
+	if len(r.SetParameters) == 0 {
+		return nil
+	}
+
 	return db.Update(func(tx *bolt.Tx) error {
-		current, err := db.CurrentService(tx)
-		if err != nil {
-			return err
+		current := r.service
+		if current == "" {
+			var err error
+			current, err = db.CurrentService(tx)
+			if err != nil {
+				return err
+			}
 		}
 
 		for param, filt := range r.SetParameters {
@@ -139,7 +229,7 @@ func (r *Response) setParameters() error {
 			// get the path for the bucket, this starts with the current service, but ends before the parameter
 			// name
 			p := strings.Split(param, ".")
-			path := strings.Join(append([]string{"services", string(current)}, p[:len(p)-1]...), ".")
+			path := strings.Join(append([]string{"services", current}, p[:len(p)-1]...), ".")
 
 			b := getBucket(tx, path)
 			if b == nil {
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRetryCondition is returned when a retry status or error setting can't be parsed
+type ErrRetryCondition struct {
+	Setting string
+	Value   string
+}
+
+func (e ErrRetryCondition) Error() string {
+	return fmt.Sprintf("invalid %s value %q", e.Setting, e.Value)
+}
+
+// ErrNoReplayBody is returned when a request body can't be recreated for a retry
+type ErrNoReplayBody struct {
+	Method string
+}
+
+func (e ErrNoReplayBody) Error() string {
+	return fmt.Sprintf("unable to resend %s request body on retry", e.Method)
+}
+
+// backoff returns how long to wait before the next attempt
+func (s Settings) backoff(attempt int) time.Duration {
+	delay := s.RetryDelay.Duration
+
+	if s.ExponentialBackoff.Bool {
+		delay *= time.Duration(math.Exp(float64(attempt)))
+	}
+
+	if s.RetryJitter.Bool && delay > 0 {
+		delay = time.Duration(rand.Intn(int(delay)))
+	}
+
+	return delay
+}
+
+// retryAfter parses the Retry-After header, it can either be a number of
+// seconds or a HTTP-date.  Returns false if there is no usable header.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	// a date in the past means we can retry immediately
+	delay := date.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay, true
+}
+
+// retryWait limits the delay asked for by the service to the retry max wait
+// so a bad Retry-After can't stall the request.  A max wait of 0 means there
+// is no limit.
+func (s Settings) retryWait(delay time.Duration) time.Duration {
+	max := s.RetryMaxWait
+	if !max.Valid {
+		max = defaultSettings.RetryMaxWait
+	}
+
+	if max.Duration > 0 && delay > max.Duration {
+		return max.Duration
+	}
+
+	return delay
+}
+
+// retryableStatus checks the status code against a comma separated list of
+// codes (429), ranges (500-599), and classes (5xx)
+func retryableStatus(statuses string, code int) (bool, error) {
+	for _, s := range strings.Split(statuses, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+
+		switch {
+		case len(s) == 3 && strings.HasSuffix(s, "xx"):
+			class, err := strconv.Atoi(s[:1])
+			if err != nil {
+				return false, ErrRetryCondition{Setting: "retry-statuses", Value: s}
+			}
+			if code/100 == class {
+				return true, nil
+			}
+
+		case strings.Contains(s, "-"):
+			bounds := strings.SplitN(s, "-", 2)
+			low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			if err != nil {
+				return false, ErrRetryCondition{Setting: "retry-statuses", Value: s}
+			}
+			high, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				return false, ErrRetryCondition{Setting: "retry-statuses", Value: s}
+			}
+			if code >= low && code <= high {
+				return true, nil
+			}
+
+		default:
+			c, err := strconv.Atoi(s)
+			if err != nil {
+				return false, ErrRetryCondition{Setting: "retry-statuses", Value: s}
+			}
+			if code == c {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// retryableError checks the transport error against a comma separated list
+// of error classes.
+func retryableError(classes string, err error) (bool, error) {
+	for _, c := range strings.Split(classes, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+
+		switch c {
+		case "":
+			continue
+		case "all":
+			return true, nil
+		case "none":
+			return false, nil
+		case "timeout", "connection", "dns", "tls":
+			if errorClass(err) == c {
+				return true, nil
+			}
+		default:
+			return false, ErrRetryCondition{Setting: "retry-errors", Value: c}
+		}
+	}
+
+	return false, nil
+}
+
+// errorClass sorts transport errors into the classes that can be retried
+func errorClass(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return "timeout"
+		}
+		return "dns"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	var recordErr tls.RecordHeaderError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+		return "tls"
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "connection"
+	}
+
+	return ""
+}
+
+// idempotent reports if the method can safely be sent more than once
+func idempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// rewind replaces the already consumed request body with a fresh copy so the
+// request can be sent again.  Returns true if a body will be resent.
+func rewind(req *http.Request) (bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return false, nil
+	}
+
+	if req.GetBody == nil {
+		return false, ErrNoReplayBody{Method: req.Method}
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return false, err
+	}
+	req.Body = body
+
+	return true, nil
+}
+
+// shouldRetry decides if the attempt should be retried based on the retry
+// settings.  Settings that have not been stored fall back to the defaults.
+func (s Settings) shouldRetry(req *http.Request, resp *http.Response, err error) (bool, error) {
+	if !idempotent(req.Method) {
+		allow := s.RetryNonIdempotent
+		if !allow.Valid {
+			allow = defaultSettings.RetryNonIdempotent
+		}
+		if !allow.Bool {
+			return false, nil
+		}
+	}
+
+	if err != nil {
+		classes := s.RetryErrors
+		if !classes.Valid {
+			classes = defaultSettings.RetryErrors
+		}
+		return retryableError(classes.String, err)
+	}
+
+	statuses := s.RetryStatuses
+	if !statuses.Valid {
+		statuses = defaultSettings.RetryStatuses
+	}
+	return retryableStatus(statuses.String, resp.StatusCode)
+}
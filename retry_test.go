@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		delay  time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"Mon, 01 Jan 2018 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 01 Jan 2018 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{Header: make(http.Header)}
+		if test.header != "" {
+			resp.Header.Set("Retry-After", test.header)
+		}
+
+		delay, ok := retryAfter(resp, now)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("%q: expected %s %t got %s %t", test.header, test.delay, test.ok, delay, ok)
+		}
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	tests := []struct {
+		statuses string
+		code     int
+		retry    bool
+	}{
+		{"500-599", 503, true},
+		{"500-599", 429, false},
+		{"429,5xx", 429, true},
+		{"429,5xx", 502, true},
+		{"429, 502-504", 501, false},
+		{"", 500, false},
+	}
+
+	for _, test := range tests {
+		retry, err := retryableStatus(test.statuses, test.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retry != test.retry {
+			t.Errorf("%q %d: expected %t", test.statuses, test.code, test.retry)
+		}
+	}
+
+	if _, err := retryableStatus("teapot", 418); err == nil {
+		t.Error("expected error for invalid status")
+	}
+}
+
+func TestRetryableError(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		classes string
+		err     error
+		retry   bool
+	}{
+		{"all", errors.New("anything"), true},
+		{"none", dial, false},
+		{"", dial, false},
+		{"connection", dial, true},
+		{"connection", io.ErrUnexpectedEOF, true},
+		{"timeout", dial, false},
+		{"timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, true},
+		{"dns", &net.DNSError{Err: "no such host"}, true},
+		{"timeout, connection", &net.DNSError{Err: "no such host"}, false},
+		{"tls", x509.UnknownAuthorityError{}, true},
+		{"tls,dns", errors.New("unknown"), false},
+	}
+
+	for _, test := range tests {
+		retry, err := retryableError(test.classes, test.err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retry != test.retry {
+			t.Errorf("%q %v: expected %t", test.classes, test.err, test.retry)
+		}
+	}
+
+	if _, err := retryableError("sometimes", dial); err == nil {
+		t.Error("expected error for invalid class")
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	var s Settings
+	if wait := s.retryWait(time.Hour); wait != defaultSettings.RetryMaxWait.Duration {
+		t.Errorf("expected the default max wait got %s", wait)
+	}
+
+	s.RetryMaxWait = NullDuration{Duration: 0, Valid: true}
+	if wait := s.retryWait(time.Hour); wait != time.Hour {
+		t.Errorf("expected no limit got %s", wait)
+	}
+
+	s.RetryMaxWait = NullDuration{Duration: time.Second, Valid: true}
+	if wait := s.retryWait(time.Millisecond); wait != time.Millisecond {
+		t.Errorf("expected a short wait to be kept got %s", wait)
+	}
+}
+
+func TestRetryAfterTooManyRequests(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+	}))
+	defer server.Close()
+
+	r := Request{Settings: NewSettings()}
+	r.Settings.Retries = sql.NullInt64{Int64: 2, Valid: true}
+	r.Settings.RetryStatuses = sql.NullString{String: "429", Valid: true}
+	r.Settings.RetryMaxWait = NullDuration{Duration: 50 * time.Millisecond, Valid: true}
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := r.retry(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("expected success on the second attempt got %d after %d", resp.StatusCode, attempts)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("expected the hour long Retry-After to be limited, waited %s", elapsed)
+	}
+}
+
+func TestRetryResendsBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}))
+	defer server.Close()
+
+	for _, allow := range []bool{true, false} {
+		bodies = nil
+
+		r := Request{Settings: NewSettings()}
+		r.Settings.Retries = sql.NullInt64{Int64: 2, Valid: true}
+		r.Settings.RetryNonIdempotent = sql.NullBool{Bool: allow, Valid: true}
+
+		req, err := http.NewRequest("POST", server.URL, strings.NewReader(`{"id": 1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := r.retry(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		attempts := 1
+		if allow {
+			attempts = 3
+		}
+		if len(bodies) != attempts {
+			t.Fatalf("expected %d attempts got %d", attempts, len(bodies))
+		}
+
+		for i, body := range bodies {
+			if body != `{"id": 1}` {
+				t.Errorf("attempt %d sent body %q", i, body)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/boltdb/bolt"
@@ -44,6 +45,14 @@ func initService() error {
 		return WriteYAMLSettings(yamlFile, db, &request)
 	}
 
+	var doc *openAPIDocument
+	if openAPIFile != "" {
+		var err error
+		if doc, err = loadOpenAPI(openAPIFile); err != nil {
+			return err
+		}
+	}
+
 	// parse flags normally
 	return db.Update(func(tx *bolt.Tx) error {
 		b, err := request.MakeServiceBucket(tx)
@@ -51,36 +60,45 @@ func initService() error {
 			return err
 		}
 
-		defaultSettings.Merge(settings)
-		settings = defaultSettings
-
-		if err := settings.Write(b); err != nil {
+		if doc != nil {
+			err = importOpenAPI(b, doc)
+		} else {
+			defaultSettings.Merge(settings)
+			settings = defaultSettings
+			err = settings.Write(b)
+		}
+		if err != nil {
 			return err
 		}
 
-		// if this is the first service to be set then set then also make it current service
-		// and initialise the db
-		info := tx.Bucket([]byte("info"))
-		if info == nil {
-			info, err = tx.CreateBucket([]byte("info"))
-			if err != nil {
-				return err
-			}
+		return initInfo(tx, request.Service)
+	})
+}
 
-			if err := info.Put([]byte("version"), []byte(versionNumber)); err != nil {
-				return err
-			}
+// initInfo initialises the db, if this is the first service to be set then
+// it also becomes the current service
+func initInfo(tx *bolt.Tx, service string) error {
+	info := tx.Bucket([]byte("info"))
+	if info == nil {
+		var err error
+		info, err = tx.CreateBucket([]byte("info"))
+		if err != nil {
+			return err
+		}
 
+		if err := info.Put([]byte("version"), []byte(versionNumber)); err != nil {
+			return err
 		}
 
-		if info.Get([]byte("current")) == nil {
-			if err := info.Put([]byte("current"), []byte(request.Service)); err != nil {
-				return err
-			}
+	}
+
+	if info.Get([]byte("current")) == nil {
+		if err := info.Put([]byte("current"), []byte(service)); err != nil {
+			return err
 		}
+	}
 
-		return nil
-	})
+	return nil
 }
 
 func removeService() error {
@@ -94,6 +112,28 @@ func removeService() error {
 			return err
 		}
 
+		// a new service with the same name starts with a fresh rate limit
+		if limits := tx.Bucket([]byte(limitsBucket)); limits != nil {
+			var keys [][]byte
+			prefix := []byte(request.Service + " ")
+			c := limits.Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+
+			for _, k := range keys {
+				if err := limits.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+
+		if cache := tx.Bucket([]byte(cacheBucket)); cache != nil && cache.Bucket([]byte(request.Service)) != nil {
+			if err := cache.DeleteBucket([]byte(request.Service)); err != nil {
+				return err
+			}
+		}
+
 		info := tx.Bucket([]byte("info"))
 		if info == nil {
 			return ErrMalformedDB{Bucket: "info"}
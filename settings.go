@@ -31,6 +31,8 @@ var (
 		Headers:    make(map[string]string),
 		Parameters: make(map[string]string),
 		Queries:    make(map[string]string),
+		Form:       make(map[string]string),
+		Files:      make(map[string]string),
 		Username:   sql.NullString{String: "", Valid: true},
 		Password:   sql.NullString{String: "", Valid: true},
 
@@ -47,6 +49,25 @@ var (
 		RetryDelay:         NullDuration{Duration: 100000000, Valid: true},
 		ExponentialBackoff: sql.NullBool{Bool: true, Valid: true},
 		RetryJitter:        sql.NullBool{Bool: true, Valid: true},
+		RetryStatuses:      sql.NullString{String: "500-599", Valid: true},
+		RetryErrors:        sql.NullString{String: "all", Valid: true},
+		RetryNonIdempotent: sql.NullBool{Bool: false, Valid: true},
+		RetryMaxWait:       NullDuration{Duration: time.Minute, Valid: true},
+
+		ConnectTimeout: NullDuration{Duration: 30 * time.Second, Valid: true},
+		TLSTimeout:     NullDuration{Duration: 10 * time.Second, Valid: true},
+		Timeout:        NullDuration{Duration: 0, Valid: true},
+		Insecure:       sql.NullBool{Bool: false, Valid: true},
+
+		PaginateMaxPages: sql.NullInt64{Int64: 100, Valid: true},
+
+		Cache:   sql.NullBool{Bool: false, Valid: true},
+		Offline: sql.NullBool{Bool: false, Valid: true},
+
+		Timing:       sql.NullBool{Bool: false, Valid: true},
+		TimingFormat: sql.NullString{String: "text", Valid: true},
+
+		OpenAPIValidate: sql.NullString{String: "off", Valid: true},
 	}
 
 	yamlFile *string
@@ -61,15 +82,32 @@ type Settings struct {
 	Headers    map[string]string
 	Parameters map[string]string
 	Queries    map[string]string
-
-	// basic auth
-	Username sql.NullString
-	Password sql.NullString
+	Form       map[string]string
+	Files      map[string]string
+
+	// auth
+	Username         sql.NullString
+	Password         sql.NullString
+	AuthType         sql.NullString
+	AuthToken        sql.NullString
+	AuthKeyName      sql.NullString
+	AuthKey          sql.NullString
+	AuthKeyIn        sql.NullString
+	AuthTokenURL     sql.NullString
+	AuthClientID     sql.NullString
+	AuthClientSecret sql.NullString
+	AuthClientAuth   sql.NullString
+	AuthGrant        sql.NullString
+	AuthScopes       sql.NullString
+	AuthRefreshToken sql.NullString
+	AuthURL          sql.NullString
+	AuthRedirectURL  sql.NullString
 
 	// output
 	Pretty        sql.NullBool
 	PrettyIndent  sql.NullString
 	Filter        sql.NullString
+	OutputFile    sql.NullString
 	SetParameters map[string]string
 
 	// hooks
@@ -81,6 +119,60 @@ type Settings struct {
 	RetryDelay         NullDuration
 	ExponentialBackoff sql.NullBool
 	RetryJitter        sql.NullBool
+	RetryStatuses      sql.NullString
+	RetryErrors        sql.NullString
+	RetryNonIdempotent sql.NullBool
+	RetryMaxWait       NullDuration
+
+	// transport
+	ConnectTimeout NullDuration
+	TLSTimeout     NullDuration
+	Timeout        NullDuration
+	CAFile         sql.NullString
+	ClientCert     sql.NullString
+	ClientKey      sql.NullString
+	Insecure       sql.NullBool
+	Proxy          sql.NullString
+	NoProxy        sql.NullString
+	Socket         sql.NullString
+
+	// signing
+	SigningType            sql.NullString
+	SigningKeyID           sql.NullString
+	SigningSecret          sql.NullString
+	SigningSessionToken    sql.NullString
+	SigningRegion          sql.NullString
+	SigningService         sql.NullString
+	SigningAlgorithm       sql.NullString
+	SigningComponents      sql.NullString
+	SigningHeader          sql.NullString
+	SigningFormat          sql.NullString
+	SigningTimestampHeader sql.NullString
+	SigningEncoding        sql.NullString
+
+	// paginate
+	PaginateType     sql.NullString
+	PaginateItems    sql.NullString
+	PaginateCursor   sql.NullString
+	PaginateParam    sql.NullString
+	PaginateStart    sql.NullInt64
+	PaginateSize     sql.NullInt64
+	PaginateMaxPages sql.NullInt64
+
+	// rate-limit
+	RateLimit sql.NullString
+	RateBurst sql.NullInt64
+
+	// cache
+	Cache   sql.NullBool
+	Offline sql.NullBool
+
+	// timing
+	Timing       sql.NullBool
+	TimingFormat sql.NullString
+	// openapi
+	OpenAPISpec     sql.NullString
+	OpenAPIValidate sql.NullString
 }
 
 type YAMLSettings struct {
@@ -110,18 +202,98 @@ type YAMLServiceSettings struct {
 	Username    *string           `yaml:"username,omitempty"`
 	Password    *string           `yaml:"password,omitempty"`
 	Parameters  map[string]string `yaml:"parameters,omitempty"`
+	Form        map[string]string `yaml:"form,omitempty"`
+	Files       map[string]string `yaml:"files,omitempty"`
 	DataHook    *string           `yaml:"data-hook,omitempty"`
 	RequestHook *string           `yaml:"request-hook,omitempty"`
 
+	Auth *YAMLAuthSettings `yaml:"auth,omitempty"`
+
 	Output *YAMLOutputSettings `yaml:"output,omitempty"`
 
 	Retry *YAMLRetrySettings `yaml:"retry,omitempty"`
+
+	Transport *YAMLTransportSettings `yaml:"transport,omitempty"`
+
+	Signing *YAMLSigningSettings `yaml:"signing,omitempty"`
+
+	Paginate *YAMLPaginateSettings `yaml:"paginate,omitempty"`
+
+	RateLimit *YAMLRateLimitSettings `yaml:"rate-limit,omitempty"`
+
+	Cache *YAMLCacheSettings `yaml:"cache,omitempty"`
+
+	Timing  *YAMLTimingSettings  `yaml:"timing,omitempty"`
+	OpenAPI *YAMLOpenAPISettings `yaml:"openapi,omitempty"`
+}
+
+type YAMLAuthSettings struct {
+	Type         *string `yaml:"type,omitempty"`
+	Token        *string `yaml:"token,omitempty"`
+	KeyName      *string `yaml:"key-name,omitempty"`
+	Key          *string `yaml:"key,omitempty"`
+	KeyIn        *string `yaml:"key-in,omitempty"`
+	TokenURL     *string `yaml:"token-url,omitempty"`
+	ClientID     *string `yaml:"client-id,omitempty"`
+	ClientSecret *string `yaml:"client-secret,omitempty"`
+	ClientAuth   *string `yaml:"client-auth,omitempty"`
+	Grant        *string `yaml:"grant,omitempty"`
+	Scopes       *string `yaml:"scopes,omitempty"`
+	RefreshToken *string `yaml:"refresh-token,omitempty"`
+	AuthURL      *string `yaml:"auth-url,omitempty"`
+	RedirectURL  *string `yaml:"redirect-url,omitempty"`
+}
+
+type YAMLSigningSettings struct {
+	Type            *string `yaml:"type,omitempty"`
+	KeyID           *string `yaml:"key-id,omitempty"`
+	Secret          *string `yaml:"secret,omitempty"`
+	SessionToken    *string `yaml:"session-token,omitempty"`
+	Region          *string `yaml:"region,omitempty"`
+	Service         *string `yaml:"service,omitempty"`
+	Algorithm       *string `yaml:"algorithm,omitempty"`
+	Components      *string `yaml:"components,omitempty"`
+	Header          *string `yaml:"header,omitempty"`
+	Format          *string `yaml:"format,omitempty"`
+	TimestampHeader *string `yaml:"timestamp-header,omitempty"`
+	Encoding        *string `yaml:"encoding,omitempty"`
+}
+
+type YAMLPaginateSettings struct {
+	Type     *string `yaml:"type,omitempty"`
+	Items    *string `yaml:"items,omitempty"`
+	Cursor   *string `yaml:"cursor,omitempty"`
+	Param    *string `yaml:"param,omitempty"`
+	Start    *int    `yaml:"start,omitempty"`
+	Size     *int    `yaml:"size,omitempty"`
+	MaxPages *int    `yaml:"max-pages,omitempty"`
+}
+
+type YAMLRateLimitSettings struct {
+	Rate  *string `yaml:"rate,omitempty"`
+	Burst *int    `yaml:"burst,omitempty"`
+}
+
+type YAMLCacheSettings struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+	Offline *bool `yaml:"offline,omitempty"`
+}
+
+type YAMLTimingSettings struct {
+	Enabled *bool   `yaml:"enabled,omitempty"`
+	Format  *string `yaml:"format,omitempty"`
+}
+
+type YAMLOpenAPISettings struct {
+	Spec     *string `yaml:"spec,omitempty"`
+	Validate *string `yaml:"validate,omitempty"`
 }
 
 type YAMLOutputSettings struct {
 	Pretty              *bool             `yaml:"pretty,omitempty"`
 	Indent              *string           `yaml:"indent,omitempty"`
 	Filter              *string           `yaml:"filter,omitempty"`
+	File                *string           `yaml:"file,omitempty"`
 	Hook                *string           `yaml:"hook,omitempty"`
 	SetFilterParameters map[string]string `yaml:"set-filter-parameters,omitempty"`
 	SetLuaParameters    map[string]string `yaml:"set-lua-parameters,omitempty"`
@@ -132,6 +304,23 @@ type YAMLRetrySettings struct {
 	Delay              *time.Duration `yaml:"delay,omitempty"`
 	ExponentialBackoff *bool          `yaml:"exponential-backoff,omitempty"`
 	Jitter             *bool          `yaml:"jitter,omitempty"`
+	Statuses           *string        `yaml:"statuses,omitempty"`
+	Errors             *string        `yaml:"errors,omitempty"`
+	NonIdempotent      *bool          `yaml:"non-idempotent,omitempty"`
+	MaxWait            *time.Duration `yaml:"max-wait,omitempty"`
+}
+
+type YAMLTransportSettings struct {
+	ConnectTimeout *time.Duration `yaml:"connect-timeout,omitempty"`
+	TLSTimeout     *time.Duration `yaml:"tls-timeout,omitempty"`
+	Timeout        *time.Duration `yaml:"timeout,omitempty"`
+	CAFile         *string        `yaml:"ca-file,omitempty"`
+	ClientCert     *string        `yaml:"client-cert,omitempty"`
+	ClientKey      *string        `yaml:"client-key,omitempty"`
+	Insecure       *bool          `yaml:"insecure,omitempty"`
+	Proxy          *string        `yaml:"proxy,omitempty"`
+	NoProxy        *string        `yaml:"no-proxy,omitempty"`
+	Socket         *string        `yaml:"socket,omitempty"`
 }
 
 func WriteYAMLSettings(filename *string, db *DB, r *Request) error {
@@ -205,8 +394,10 @@ func (s *YAMLSettings) Write(db *DB, r *Request) error {
 					return err
 				}
 
-				if err := setString(b, "data", v.Data); err != nil {
-					return err
+				if v.Data != nil {
+					if err := storeAliasData(b, *v.Data); err != nil {
+						return err
+					}
 				}
 
 				if err := v.Settings.Write(b); err != nil {
@@ -276,8 +467,7 @@ func (s *YAMLSettings) Read(b *bolt.Bucket) error {
 				as.Method = string(buf)
 			}
 
-			if buf := read(aliasBucket, "data"); buf != nil {
-				data := string(buf)
+			if data := storedAliasData(aliasBucket); data != "" {
 				as.Data = &data
 			}
 
@@ -327,6 +517,14 @@ func (s *YAMLServiceSettings) Write(b *bolt.Bucket) error {
 		return err
 	}
 
+	if err := writeMap(b, "form", s.Form); err != nil {
+		return err
+	}
+
+	if err := writeMap(b, "files", s.Files); err != nil {
+		return err
+	}
+
 	if err := write(b, "data-hook", s.DataHook); err != nil {
 		return err
 	}
@@ -335,6 +533,64 @@ func (s *YAMLServiceSettings) Write(b *bolt.Bucket) error {
 		return err
 	}
 
+	if s.Auth != nil {
+		if err := write(b, "auth.type", s.Auth.Type); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.token", s.Auth.Token); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.key-name", s.Auth.KeyName); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.key", s.Auth.Key); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.key-in", s.Auth.KeyIn); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.token-url", s.Auth.TokenURL); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.client-id", s.Auth.ClientID); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.client-secret", s.Auth.ClientSecret); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.client-auth", s.Auth.ClientAuth); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.grant", s.Auth.Grant); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.scopes", s.Auth.Scopes); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.refresh-token", s.Auth.RefreshToken); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.auth-url", s.Auth.AuthURL); err != nil {
+			return err
+		}
+
+		if err := write(b, "auth.redirect-url", s.Auth.RedirectURL); err != nil {
+			return err
+		}
+	}
+
 	if s.Output != nil {
 		if err := write(b, "output.pretty", s.Output.Pretty); err != nil {
 			return err
@@ -348,6 +604,10 @@ func (s *YAMLServiceSettings) Write(b *bolt.Bucket) error {
 			return err
 		}
 
+		if err := write(b, "output.file", s.Output.File); err != nil {
+			return err
+		}
+
 		if err := writeMap(b, "output.set-filter-parameters", s.Output.SetFilterParameters); err != nil {
 			return err
 		}
@@ -377,6 +637,184 @@ func (s *YAMLServiceSettings) Write(b *bolt.Bucket) error {
 		if err := write(b, "retry.jitter", s.Retry.Jitter); err != nil {
 			return err
 		}
+
+		if err := write(b, "retry.statuses", s.Retry.Statuses); err != nil {
+			return err
+		}
+
+		if err := write(b, "retry.errors", s.Retry.Errors); err != nil {
+			return err
+		}
+
+		if err := write(b, "retry.non-idempotent", s.Retry.NonIdempotent); err != nil {
+			return err
+		}
+
+		if err := write(b, "retry.max-wait", s.Retry.MaxWait); err != nil {
+			return err
+		}
+	}
+
+	if s.Transport != nil {
+		if err := write(b, "transport.connect-timeout", s.Transport.ConnectTimeout); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.tls-timeout", s.Transport.TLSTimeout); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.timeout", s.Transport.Timeout); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.ca-file", s.Transport.CAFile); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.client-cert", s.Transport.ClientCert); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.client-key", s.Transport.ClientKey); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.insecure", s.Transport.Insecure); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.proxy", s.Transport.Proxy); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.no-proxy", s.Transport.NoProxy); err != nil {
+			return err
+		}
+
+		if err := write(b, "transport.socket", s.Transport.Socket); err != nil {
+			return err
+		}
+	}
+
+	if s.Signing != nil {
+		if err := write(b, "signing.type", s.Signing.Type); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.key-id", s.Signing.KeyID); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.secret", s.Signing.Secret); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.session-token", s.Signing.SessionToken); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.region", s.Signing.Region); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.service", s.Signing.Service); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.algorithm", s.Signing.Algorithm); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.components", s.Signing.Components); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.header", s.Signing.Header); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.format", s.Signing.Format); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.timestamp-header", s.Signing.TimestampHeader); err != nil {
+			return err
+		}
+
+		if err := write(b, "signing.encoding", s.Signing.Encoding); err != nil {
+			return err
+		}
+	}
+
+	if s.Paginate != nil {
+		if err := write(b, "paginate.type", s.Paginate.Type); err != nil {
+			return err
+		}
+
+		if err := write(b, "paginate.items", s.Paginate.Items); err != nil {
+			return err
+		}
+
+		if err := write(b, "paginate.cursor", s.Paginate.Cursor); err != nil {
+			return err
+		}
+
+		if err := write(b, "paginate.param", s.Paginate.Param); err != nil {
+			return err
+		}
+
+		if err := write(b, "paginate.start", s.Paginate.Start); err != nil {
+			return err
+		}
+
+		if err := write(b, "paginate.size", s.Paginate.Size); err != nil {
+			return err
+		}
+
+		if err := write(b, "paginate.max-pages", s.Paginate.MaxPages); err != nil {
+			return err
+		}
+	}
+
+	if s.RateLimit != nil {
+		if err := write(b, "rate-limit.rate", s.RateLimit.Rate); err != nil {
+			return err
+		}
+
+		if err := write(b, "rate-limit.burst", s.RateLimit.Burst); err != nil {
+			return err
+		}
+	}
+
+	if s.Cache != nil {
+		if err := write(b, "cache.enabled", s.Cache.Enabled); err != nil {
+			return err
+		}
+
+		if err := write(b, "cache.offline", s.Cache.Offline); err != nil {
+			return err
+		}
+	}
+
+	if s.Timing != nil {
+		if err := write(b, "timing.enabled", s.Timing.Enabled); err != nil {
+			return err
+		}
+
+		if err := write(b, "timing.format", s.Timing.Format); err != nil {
+			return err
+		}
+	}
+
+	if s.OpenAPI != nil {
+		if err := write(b, "openapi.spec", s.OpenAPI.Spec); err != nil {
+			return err
+		}
+
+		if err := write(b, "openapi.validate", s.OpenAPI.Validate); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -435,14 +873,35 @@ func (s *YAMLServiceSettings) Read(b *bolt.Bucket) error {
 	readString("username", s.Username)
 	readString("password", s.Password)
 	readMap("parameters", s.Parameters)
+	readMap("form", s.Form)
+	readMap("files", s.Files)
 	readString("data-hook", s.DataHook)
 	readString("request-hook", s.RequestHook)
 
+	if b.Bucket([]byte("auth")) != nil {
+		s.Auth = &YAMLAuthSettings{}
+		readString("auth.type", s.Auth.Type)
+		readString("auth.token", s.Auth.Token)
+		readString("auth.key-name", s.Auth.KeyName)
+		readString("auth.key", s.Auth.Key)
+		readString("auth.key-in", s.Auth.KeyIn)
+		readString("auth.token-url", s.Auth.TokenURL)
+		readString("auth.client-id", s.Auth.ClientID)
+		readString("auth.client-secret", s.Auth.ClientSecret)
+		readString("auth.client-auth", s.Auth.ClientAuth)
+		readString("auth.grant", s.Auth.Grant)
+		readString("auth.scopes", s.Auth.Scopes)
+		readString("auth.refresh-token", s.Auth.RefreshToken)
+		readString("auth.auth-url", s.Auth.AuthURL)
+		readString("auth.redirect-url", s.Auth.RedirectURL)
+	}
+
 	if b.Bucket([]byte("output")) != nil {
 		s.Output = &YAMLOutputSettings{}
 		readBool("output.pretty", s.Output.Pretty)
 		readString("output.indent", s.Output.Indent)
 		readString("output.filter", s.Output.Filter)
+		readString("output.file", s.Output.File)
 		readString("output.response-hook", s.Output.Hook)
 		readMap("output.set-filter-parameters", s.Output.SetFilterParameters)
 		readMap("output.set-lua-parameters", s.Output.SetLuaParameters)
@@ -454,6 +913,75 @@ func (s *YAMLServiceSettings) Read(b *bolt.Bucket) error {
 		readBool("retry.exponential-backoff", s.Retry.ExponentialBackoff)
 		readDuration("retry.delay", s.Retry.Delay)
 		readBool("retry.jitter", s.Retry.Jitter)
+		readString("retry.statuses", s.Retry.Statuses)
+		readString("retry.errors", s.Retry.Errors)
+		readBool("retry.non-idempotent", s.Retry.NonIdempotent)
+		readDuration("retry.max-wait", s.Retry.MaxWait)
+	}
+
+	if b.Bucket([]byte("transport")) != nil {
+		s.Transport = &YAMLTransportSettings{}
+		readDuration("transport.connect-timeout", s.Transport.ConnectTimeout)
+		readDuration("transport.tls-timeout", s.Transport.TLSTimeout)
+		readDuration("transport.timeout", s.Transport.Timeout)
+		readString("transport.ca-file", s.Transport.CAFile)
+		readString("transport.client-cert", s.Transport.ClientCert)
+		readString("transport.client-key", s.Transport.ClientKey)
+		readBool("transport.insecure", s.Transport.Insecure)
+		readString("transport.proxy", s.Transport.Proxy)
+		readString("transport.no-proxy", s.Transport.NoProxy)
+		readString("transport.socket", s.Transport.Socket)
+	}
+
+	if b.Bucket([]byte("signing")) != nil {
+		s.Signing = &YAMLSigningSettings{}
+		readString("signing.type", s.Signing.Type)
+		readString("signing.key-id", s.Signing.KeyID)
+		readString("signing.secret", s.Signing.Secret)
+		readString("signing.session-token", s.Signing.SessionToken)
+		readString("signing.region", s.Signing.Region)
+		readString("signing.service", s.Signing.Service)
+		readString("signing.algorithm", s.Signing.Algorithm)
+		readString("signing.components", s.Signing.Components)
+		readString("signing.header", s.Signing.Header)
+		readString("signing.format", s.Signing.Format)
+		readString("signing.timestamp-header", s.Signing.TimestampHeader)
+		readString("signing.encoding", s.Signing.Encoding)
+	}
+
+	if b.Bucket([]byte("paginate")) != nil {
+		s.Paginate = &YAMLPaginateSettings{}
+		readString("paginate.type", s.Paginate.Type)
+		readString("paginate.items", s.Paginate.Items)
+		readString("paginate.cursor", s.Paginate.Cursor)
+		readString("paginate.param", s.Paginate.Param)
+		readInt("paginate.start", s.Paginate.Start)
+		readInt("paginate.size", s.Paginate.Size)
+		readInt("paginate.max-pages", s.Paginate.MaxPages)
+	}
+
+	if b.Bucket([]byte("rate-limit")) != nil {
+		s.RateLimit = &YAMLRateLimitSettings{}
+		readString("rate-limit.rate", s.RateLimit.Rate)
+		readInt("rate-limit.burst", s.RateLimit.Burst)
+	}
+
+	if b.Bucket([]byte("cache")) != nil {
+		s.Cache = &YAMLCacheSettings{}
+		readBool("cache.enabled", s.Cache.Enabled)
+		readBool("cache.offline", s.Cache.Offline)
+	}
+
+	if b.Bucket([]byte("timing")) != nil {
+		s.Timing = &YAMLTimingSettings{}
+		readBool("timing.enabled", s.Timing.Enabled)
+		readString("timing.format", s.Timing.Format)
+	}
+
+	if b.Bucket([]byte("openapi")) != nil {
+		s.OpenAPI = &YAMLOpenAPISettings{}
+		readString("openapi.spec", s.OpenAPI.Spec)
+		readString("openapi.validate", s.OpenAPI.Validate)
 	}
 
 	return nil
@@ -465,10 +993,19 @@ func NewSettings() Settings {
 		Headers:       make(map[string]string),
 		Parameters:    make(map[string]string),
 		Queries:       make(map[string]string),
+		Form:          make(map[string]string),
+		Files:         make(map[string]string),
 		SetParameters: make(map[string]string),
 	}
 }
 
+// Clone returns a copy of the settings that doesn't share any maps
+func (s Settings) Clone() Settings {
+	c := NewSettings()
+	c.Merge(s)
+	return c
+}
+
 // Merge the provided settings into calling settings struct
 func (s *Settings) Merge(other Settings) {
 	mergeString(&s.Scheme, other.Scheme)
@@ -478,12 +1015,29 @@ func (s *Settings) Merge(other Settings) {
 	mergeMap(s.Headers, other.Headers)
 	mergeMap(s.Parameters, other.Parameters)
 	mergeMap(s.Queries, other.Queries)
+	mergeMap(s.Form, other.Form)
+	mergeMap(s.Files, other.Files)
 	mergeString(&s.Username, other.Username)
 	mergeString(&s.Password, other.Password)
+	mergeString(&s.AuthType, other.AuthType)
+	mergeString(&s.AuthToken, other.AuthToken)
+	mergeString(&s.AuthKeyName, other.AuthKeyName)
+	mergeString(&s.AuthKey, other.AuthKey)
+	mergeString(&s.AuthKeyIn, other.AuthKeyIn)
+	mergeString(&s.AuthTokenURL, other.AuthTokenURL)
+	mergeString(&s.AuthClientID, other.AuthClientID)
+	mergeString(&s.AuthClientSecret, other.AuthClientSecret)
+	mergeString(&s.AuthClientAuth, other.AuthClientAuth)
+	mergeString(&s.AuthGrant, other.AuthGrant)
+	mergeString(&s.AuthScopes, other.AuthScopes)
+	mergeString(&s.AuthRefreshToken, other.AuthRefreshToken)
+	mergeString(&s.AuthURL, other.AuthURL)
+	mergeString(&s.AuthRedirectURL, other.AuthRedirectURL)
 
 	mergeBool(&s.Pretty, other.Pretty)
 	mergeString(&s.PrettyIndent, other.PrettyIndent)
 	mergeString(&s.Filter, other.Filter)
+	mergeString(&s.OutputFile, other.OutputFile)
 	mergeMap(s.SetParameters, other.SetParameters)
 
 	mergeString(&s.ResponseHook, other.ResponseHook)
@@ -494,6 +1048,54 @@ func (s *Settings) Merge(other Settings) {
 	mergeDuration(&s.RetryDelay, other.RetryDelay)
 	mergeBool(&s.ExponentialBackoff, other.ExponentialBackoff)
 	mergeBool(&s.RetryJitter, other.RetryJitter)
+	mergeString(&s.RetryStatuses, other.RetryStatuses)
+	mergeString(&s.RetryErrors, other.RetryErrors)
+	mergeBool(&s.RetryNonIdempotent, other.RetryNonIdempotent)
+	mergeDuration(&s.RetryMaxWait, other.RetryMaxWait)
+
+	mergeDuration(&s.ConnectTimeout, other.ConnectTimeout)
+	mergeDuration(&s.TLSTimeout, other.TLSTimeout)
+	mergeDuration(&s.Timeout, other.Timeout)
+	mergeString(&s.CAFile, other.CAFile)
+	mergeString(&s.ClientCert, other.ClientCert)
+	mergeString(&s.ClientKey, other.ClientKey)
+	mergeBool(&s.Insecure, other.Insecure)
+	mergeString(&s.Proxy, other.Proxy)
+	mergeString(&s.NoProxy, other.NoProxy)
+	mergeString(&s.Socket, other.Socket)
+
+	mergeString(&s.SigningType, other.SigningType)
+	mergeString(&s.SigningKeyID, other.SigningKeyID)
+	mergeString(&s.SigningSecret, other.SigningSecret)
+	mergeString(&s.SigningSessionToken, other.SigningSessionToken)
+	mergeString(&s.SigningRegion, other.SigningRegion)
+	mergeString(&s.SigningService, other.SigningService)
+	mergeString(&s.SigningAlgorithm, other.SigningAlgorithm)
+	mergeString(&s.SigningComponents, other.SigningComponents)
+	mergeString(&s.SigningHeader, other.SigningHeader)
+	mergeString(&s.SigningFormat, other.SigningFormat)
+	mergeString(&s.SigningTimestampHeader, other.SigningTimestampHeader)
+	mergeString(&s.SigningEncoding, other.SigningEncoding)
+
+	mergeString(&s.PaginateType, other.PaginateType)
+	mergeString(&s.PaginateItems, other.PaginateItems)
+	mergeString(&s.PaginateCursor, other.PaginateCursor)
+	mergeString(&s.PaginateParam, other.PaginateParam)
+	mergeInt(&s.PaginateStart, other.PaginateStart)
+	mergeInt(&s.PaginateSize, other.PaginateSize)
+	mergeInt(&s.PaginateMaxPages, other.PaginateMaxPages)
+
+	mergeString(&s.RateLimit, other.RateLimit)
+	mergeInt(&s.RateBurst, other.RateBurst)
+
+	mergeBool(&s.Cache, other.Cache)
+	mergeBool(&s.Offline, other.Offline)
+
+	mergeBool(&s.Timing, other.Timing)
+	mergeString(&s.TimingFormat, other.TimingFormat)
+
+	mergeString(&s.OpenAPISpec, other.OpenAPISpec)
+	mergeString(&s.OpenAPIValidate, other.OpenAPIValidate)
 }
 
 func mergeString(a *sql.NullString, b sql.NullString) {
@@ -574,6 +1176,20 @@ func (s *Settings) Flags(cmd *kingpin.CmdClause, hide bool) {
 
 	stringFlag("username", "set basic auth username", "", &s.Username)
 	stringFlag("password", "set basic auth password, NOTE: stored in plain text", "", &s.Password)
+	stringFlag("auth-type", "how to authenticate with the service, one of none, basic, bearer, api-key, digest", "", &s.AuthType)
+	stringFlag("auth-token", "token used by bearer auth, NOTE: stored in plain text", "", &s.AuthToken)
+	stringFlag("auth-key-name", "header or query parameter name used by api-key auth", "", &s.AuthKeyName)
+	stringFlag("auth-key", "key used by api-key auth, NOTE: stored in plain text", "", &s.AuthKey)
+	stringFlag("auth-key-in", "where api-key auth sends the key, either header or query", "", &s.AuthKeyIn)
+	stringFlag("auth-token-url", "token endpoint used by oauth2 auth", "", &s.AuthTokenURL)
+	stringFlag("auth-client-id", "client id used by oauth2 auth", "", &s.AuthClientID)
+	stringFlag("auth-client-secret", "client secret used by oauth2 auth, NOTE: stored in plain text", "", &s.AuthClientSecret)
+	stringFlag("auth-client-auth", "how oauth2 sends the client credentials, either header or body", "", &s.AuthClientAuth)
+	stringFlag("auth-grant", "oauth2 grant used to get tokens, one of client-credentials, refresh-token, authorization-code", "", &s.AuthGrant)
+	stringFlag("auth-scopes", "space separated oauth2 scopes to request", "", &s.AuthScopes)
+	stringFlag("auth-refresh-token", "refresh token used by the oauth2 refresh-token grant, NOTE: stored in plain text", "", &s.AuthRefreshToken)
+	stringFlag("auth-url", "authorization endpoint used by the oauth2 authorization-code grant", "", &s.AuthURL)
+	stringFlag("auth-redirect-url", "loopback redirect url for the oauth2 authorization-code grant, defaults to a random port on 127.0.0.1", "", &s.AuthRedirectURL)
 
 	boolFlag("pretty", "pretty print json output, removes quotes when filtering", df.Pretty.Bool, &s.Pretty)
 
@@ -581,6 +1197,8 @@ func (s *Settings) Flags(cmd *kingpin.CmdClause, hide bool) {
 
 	stringFlag("filter", "pull parts out of the returned json. use [#] to access specific elements from an array, use the key name to access the key. eg. '[0].id', 'id', and 'things.[1]', for more filter options look at http://jmespath.org/ as filter uses JMESPath", "", &s.Filter)
 
+	stringFlag("output", "stream the response body to this file instead of displaying it, hooks and filters are skipped", "", &s.OutputFile)
+
 	mapFlag("set-parameter", "takes the form 'parameter.path=filter-expression' The parameter.path is a period separated path to the bucket where the parameter must be set.  filter-expression is a JMESPath expression that will be used to determine what the parameter is set to.  If the filter returns nothing, then the parameter is unset", &s.SetParameters)
 
 	stringFlag("response-hook", "run lua script on response, happens before filtering", "", &s.ResponseHook)
@@ -591,6 +1209,67 @@ func (s *Settings) Flags(cmd *kingpin.CmdClause, hide bool) {
 	durationFlag("retry-delay", "how long to wait between retries, accepts a duration", df.RetryDelay.Duration, &s.RetryDelay)
 	boolFlag("exponential-backoff", "wether retries should exponentially backoff, uses the retry delay", df.ExponentialBackoff.Bool, &s.ExponentialBackoff)
 	boolFlag("retry-jitter", "adds jitter to retry delay", df.RetryJitter.Bool, &s.RetryJitter)
+	stringFlag("retry-statuses", "comma separated status codes that should be retried, accepts codes, ranges, and classes eg. '429,502-504,5xx'", df.RetryStatuses.String, &s.RetryStatuses)
+	stringFlag("retry-errors", "comma separated transport errors that should be retried, one of all, none, timeout, connection, dns, tls", df.RetryErrors.String, &s.RetryErrors)
+	boolFlag("retry-non-idempotent", "allow retrying non-idempotent requests such as POST and PATCH", df.RetryNonIdempotent.Bool, &s.RetryNonIdempotent)
+	durationFlag("retry-max-wait", "the longest a Retry-After header from the service may delay a retry, 0 means no limit", df.RetryMaxWait.Duration, &s.RetryMaxWait)
+
+	durationFlag("connect-timeout", "how long to wait for a connection to the service", df.ConnectTimeout.Duration, &s.ConnectTimeout)
+	durationFlag("tls-timeout", "how long to wait for the TLS handshake", df.TLSTimeout.Duration, &s.TLSTimeout)
+	durationFlag("timeout", "how long the entire request may take, 0 means no timeout", df.Timeout.Duration, &s.Timeout)
+	stringFlag("ca-file", "PEM encoded CA bundle used to verify the service", "", &s.CAFile)
+	stringFlag("client-cert", "PEM encoded client certificate", "", &s.ClientCert)
+	stringFlag("client-key", "PEM encoded client certificate key", "", &s.ClientKey)
+	boolFlag("insecure", "skip TLS certificate verification, only use this for development", df.Insecure.Bool, &s.Insecure)
+	stringFlag("proxy", "proxy URL to send requests through, defaults to the environment proxy settings", "", &s.Proxy)
+	stringFlag("no-proxy", "comma separated hosts that should not use the proxy", "", &s.NoProxy)
+	stringFlag("socket", "connect to this unix socket instead of the host, the host is still used in the url and Host header", "", &s.Socket)
+
+	stringFlag("signing-type", "how to sign requests, one of none, aws-sigv4, hmac", "", &s.SigningType)
+	stringFlag("signing-key-id", "access key id used to sign requests", "", &s.SigningKeyID)
+	stringFlag("signing-secret", "secret key used to sign requests, NOTE: stored in plain text", "", &s.SigningSecret)
+	stringFlag("signing-session-token", "aws session token sent with signed requests", "", &s.SigningSessionToken)
+	stringFlag("signing-region", "aws region used by aws-sigv4 signing", "", &s.SigningRegion)
+	stringFlag("signing-service", "aws service name used by aws-sigv4 signing", "", &s.SigningService)
+	stringFlag("signing-algorithm", "hash used by hmac signing, one of sha1, sha256, sha512", "", &s.SigningAlgorithm)
+	stringFlag("signing-components", "comma separated parts of the request hmac signs, from method, path, query, host, timestamp, body-hash, and header:<name>", "", &s.SigningComponents)
+	stringFlag("signing-header", "header hmac signing puts the signature in, defaults to Authorization", "", &s.SigningHeader)
+	stringFlag("signing-format", "format of the hmac signature header, can use {key-id}, {signature}, {timestamp}, and {algorithm}", "", &s.SigningFormat)
+	stringFlag("signing-timestamp-header", "header hmac signing sends the unix timestamp in, defaults to X-Timestamp", "", &s.SigningTimestampHeader)
+	stringFlag("signing-encoding", "encoding of the hmac signature, either hex or base64", "", &s.SigningEncoding)
+
+	stringFlag("paginate", "how to fetch the following pages of a list, one of none, link, cursor, page, or offset", "", &s.PaginateType)
+	stringFlag("paginate-items", "JMESPath expression selecting the array of items in each page, defaults to the whole body", "", &s.PaginateItems)
+	stringFlag("paginate-cursor", "JMESPath expression selecting the next cursor from the body for cursor pagination", "", &s.PaginateCursor)
+	stringFlag("paginate-param", "query parameter used to request the next page, defaults to cursor, page, or offset", "", &s.PaginateParam)
+	intFlag("paginate-start", "first page number or offset, defaults to 1 for page and 0 for offset", "", &s.PaginateStart)
+	intFlag("paginate-size", "number of items in a full page, offset pagination advances by this amount", "", &s.PaginateSize)
+	intFlag("max-pages", "the most pages that will be fetched", strconv.Itoa(int(df.PaginateMaxPages.Int64)), &s.PaginateMaxPages)
+
+	stringFlag("rate-limit", "most requests to send to the service, shared by every rest process, eg. 10/s, 100/m, or 5000/h", "", &s.RateLimit)
+	intFlag("rate-burst", "how many requests can be sent at once before the rate limit applies, defaults to 1", "", &s.RateBurst)
+
+	boolFlag("cache", "cache GET responses and revalidate them with ETag and Last-Modified", df.Cache.Bool, &s.Cache)
+	boolFlag("offline", "serve responses from the cache without sending the request", df.Offline.Bool, &s.Offline)
+
+	boolFlag("timing", "show how long each phase of every attempt took, dns, connect, tls, server, and transfer", df.Timing.Bool, &s.Timing)
+	stringFlag("timing-format", "format of the timing breakdown, either text or json", df.TimingFormat.String, &s.TimingFormat)
+
+	stringFlag("openapi-spec", "OpenAPI or Swagger document describing the service, used to validate requests and responses", "", &s.OpenAPISpec)
+	stringFlag("openapi-validate", "validate requests and responses against the openapi spec, either off, warn, or error", df.OpenAPIValidate.String, &s.OpenAPIValidate)
+}
+
+// FormFlags attach the form flags to commands that send a body
+func (s *Settings) FormFlags(cmd *kingpin.CmdClause, hide bool) {
+	form := cmd.Flag("form", "set form field for request, takes the form key=value")
+	file := cmd.Flag("file", "upload a file as a multipart form field, takes the form field=@path")
+	if hide {
+		form = form.Hidden()
+		file = file.Hidden()
+	}
+
+	form.StringMapVar(&s.Form)
+	file.StringMapVar(&s.Files)
 }
 
 func (s *Settings) YAMLFlag(cmd *kingpin.CmdClause) {
@@ -630,6 +1309,14 @@ func (s Settings) Write(b *bolt.Bucket) error {
 		return err
 	}
 
+	if err := writeMap(b, "form", s.Form); err != nil {
+		return err
+	}
+
+	if err := writeMap(b, "files", s.Files); err != nil {
+		return err
+	}
+
 	if err := writeString(b, "username", s.Username); err != nil {
 		return err
 	}
@@ -638,6 +1325,62 @@ func (s Settings) Write(b *bolt.Bucket) error {
 		return err
 	}
 
+	if err := writeString(b, "auth.type", s.AuthType); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.token", s.AuthToken); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.key-name", s.AuthKeyName); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.key", s.AuthKey); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.key-in", s.AuthKeyIn); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.token-url", s.AuthTokenURL); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.client-id", s.AuthClientID); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.client-secret", s.AuthClientSecret); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.client-auth", s.AuthClientAuth); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.grant", s.AuthGrant); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.scopes", s.AuthScopes); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.refresh-token", s.AuthRefreshToken); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.auth-url", s.AuthURL); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "auth.redirect-url", s.AuthRedirectURL); err != nil {
+		return err
+	}
+
 	if err := writeBool(b, "output.pretty", s.Pretty); err != nil {
 		return err
 	}
@@ -650,6 +1393,10 @@ func (s Settings) Write(b *bolt.Bucket) error {
 		return err
 	}
 
+	if err := writeString(b, "output.file", s.OutputFile); err != nil {
+		return err
+	}
+
 	if err := writeMap(b, "output.set-filter-parameters", s.SetParameters); err != nil {
 		return err
 	}
@@ -682,6 +1429,170 @@ func (s Settings) Write(b *bolt.Bucket) error {
 		return err
 	}
 
+	if err := writeString(b, "retry.statuses", s.RetryStatuses); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "retry.errors", s.RetryErrors); err != nil {
+		return err
+	}
+
+	if err := writeBool(b, "retry.non-idempotent", s.RetryNonIdempotent); err != nil {
+		return err
+	}
+
+	if err := writeDuration(b, "retry.max-wait", s.RetryMaxWait); err != nil {
+		return err
+	}
+
+	if err := writeDuration(b, "transport.connect-timeout", s.ConnectTimeout); err != nil {
+		return err
+	}
+
+	if err := writeDuration(b, "transport.tls-timeout", s.TLSTimeout); err != nil {
+		return err
+	}
+
+	if err := writeDuration(b, "transport.timeout", s.Timeout); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "transport.ca-file", s.CAFile); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "transport.client-cert", s.ClientCert); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "transport.client-key", s.ClientKey); err != nil {
+		return err
+	}
+
+	if err := writeBool(b, "transport.insecure", s.Insecure); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "transport.proxy", s.Proxy); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "transport.no-proxy", s.NoProxy); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "transport.socket", s.Socket); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.type", s.SigningType); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.key-id", s.SigningKeyID); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.secret", s.SigningSecret); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.session-token", s.SigningSessionToken); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.region", s.SigningRegion); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.service", s.SigningService); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.algorithm", s.SigningAlgorithm); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.components", s.SigningComponents); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.header", s.SigningHeader); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.format", s.SigningFormat); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.timestamp-header", s.SigningTimestampHeader); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "signing.encoding", s.SigningEncoding); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "paginate.type", s.PaginateType); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "paginate.items", s.PaginateItems); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "paginate.cursor", s.PaginateCursor); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "paginate.param", s.PaginateParam); err != nil {
+		return err
+	}
+
+	if err := writeInt(b, "paginate.start", s.PaginateStart); err != nil {
+		return err
+	}
+
+	if err := writeInt(b, "paginate.size", s.PaginateSize); err != nil {
+		return err
+	}
+
+	if err := writeInt(b, "paginate.max-pages", s.PaginateMaxPages); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "rate-limit.rate", s.RateLimit); err != nil {
+		return err
+	}
+
+	if err := writeInt(b, "rate-limit.burst", s.RateBurst); err != nil {
+		return err
+	}
+
+	if err := writeBool(b, "cache.enabled", s.Cache); err != nil {
+		return err
+	}
+
+	if err := writeBool(b, "cache.offline", s.Offline); err != nil {
+		return err
+	}
+
+	if err := writeBool(b, "timing.enabled", s.Timing); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "timing.format", s.TimingFormat); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "openapi.spec", s.OpenAPISpec); err != nil {
+		return err
+	}
+
+	if err := writeString(b, "openapi.validate", s.OpenAPIValidate); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -694,11 +1605,28 @@ func (s *Settings) Read(b *bolt.Bucket) {
 	bucketMap(b.Bucket([]byte("headers")), &s.Headers)
 	bucketMap(b.Bucket([]byte("parameters")), &s.Parameters)
 	bucketMap(b.Bucket([]byte("queries")), &s.Queries)
+	bucketMap(b.Bucket([]byte("form")), &s.Form)
+	bucketMap(b.Bucket([]byte("files")), &s.Files)
 	s.Username = readString(b, "username")
 	s.Password = readString(b, "password")
+	s.AuthType = readString(b, "auth.type")
+	s.AuthToken = readString(b, "auth.token")
+	s.AuthKeyName = readString(b, "auth.key-name")
+	s.AuthKey = readString(b, "auth.key")
+	s.AuthKeyIn = readString(b, "auth.key-in")
+	s.AuthTokenURL = readString(b, "auth.token-url")
+	s.AuthClientID = readString(b, "auth.client-id")
+	s.AuthClientSecret = readString(b, "auth.client-secret")
+	s.AuthClientAuth = readString(b, "auth.client-auth")
+	s.AuthGrant = readString(b, "auth.grant")
+	s.AuthScopes = readString(b, "auth.scopes")
+	s.AuthRefreshToken = readString(b, "auth.refresh-token")
+	s.AuthURL = readString(b, "auth.auth-url")
+	s.AuthRedirectURL = readString(b, "auth.redirect-url")
 	s.Pretty = readBool(b, "output.pretty")
 	s.PrettyIndent = readString(b, "output.indent")
 	s.Filter = readString(b, "output.filter")
+	s.OutputFile = readString(b, "output.file")
 	bucketMap(b.Bucket([]byte("output.set-filter-parameters")), &s.SetParameters)
 	s.ResponseHook = readString(b, "output.response-hook")
 	s.RequestDataHook = readString(b, "data-hook")
@@ -708,6 +1636,54 @@ func (s *Settings) Read(b *bolt.Bucket) {
 	s.RetryDelay = readDuration(b, "retry.delay")
 	s.ExponentialBackoff = readBool(b, "retry.exponential-backoff")
 	s.RetryJitter = readBool(b, "retry.jitter")
+	s.RetryStatuses = readString(b, "retry.statuses")
+	s.RetryErrors = readString(b, "retry.errors")
+	s.RetryNonIdempotent = readBool(b, "retry.non-idempotent")
+	s.RetryMaxWait = readDuration(b, "retry.max-wait")
+
+	s.ConnectTimeout = readDuration(b, "transport.connect-timeout")
+	s.TLSTimeout = readDuration(b, "transport.tls-timeout")
+	s.Timeout = readDuration(b, "transport.timeout")
+	s.CAFile = readString(b, "transport.ca-file")
+	s.ClientCert = readString(b, "transport.client-cert")
+	s.ClientKey = readString(b, "transport.client-key")
+	s.Insecure = readBool(b, "transport.insecure")
+	s.Proxy = readString(b, "transport.proxy")
+	s.NoProxy = readString(b, "transport.no-proxy")
+	s.Socket = readString(b, "transport.socket")
+
+	s.SigningType = readString(b, "signing.type")
+	s.SigningKeyID = readString(b, "signing.key-id")
+	s.SigningSecret = readString(b, "signing.secret")
+	s.SigningSessionToken = readString(b, "signing.session-token")
+	s.SigningRegion = readString(b, "signing.region")
+	s.SigningService = readString(b, "signing.service")
+	s.SigningAlgorithm = readString(b, "signing.algorithm")
+	s.SigningComponents = readString(b, "signing.components")
+	s.SigningHeader = readString(b, "signing.header")
+	s.SigningFormat = readString(b, "signing.format")
+	s.SigningTimestampHeader = readString(b, "signing.timestamp-header")
+	s.SigningEncoding = readString(b, "signing.encoding")
+
+	s.PaginateType = readString(b, "paginate.type")
+	s.PaginateItems = readString(b, "paginate.items")
+	s.PaginateCursor = readString(b, "paginate.cursor")
+	s.PaginateParam = readString(b, "paginate.param")
+	s.PaginateStart = readInt(b, "paginate.start")
+	s.PaginateSize = readInt(b, "paginate.size")
+	s.PaginateMaxPages = readInt(b, "paginate.max-pages")
+
+	s.RateLimit = readString(b, "rate-limit.rate")
+	s.RateBurst = readInt(b, "rate-limit.burst")
+
+	s.Cache = readBool(b, "cache.enabled")
+	s.Offline = readBool(b, "cache.offline")
+
+	s.Timing = readBool(b, "timing.enabled")
+	s.TimingFormat = readString(b, "timing.format")
+
+	s.OpenAPISpec = readString(b, "openapi.spec")
+	s.OpenAPIValidate = readString(b, "openapi.validate")
 }
 
 // URL for the service
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs a fully prepared request, it is called before every attempt
+// so the signature timestamp is always fresh
+type Signer interface {
+	Sign(req *http.Request, now time.Time) error
+
+	// Header is the header the signature is written to
+	Header() string
+}
+
+// signers are all the available signing types
+var signers = map[string]func(s Settings, replace func(string) string) (Signer, error){
+	"aws-sigv4": newAWSSigner,
+	"hmac":      newHMACSigner,
+}
+
+type ErrSigningType struct {
+	Type string
+}
+
+func (e ErrSigningType) Error() string {
+	types := make([]string, 0, len(signers))
+	for t := range signers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return fmt.Sprintf("unknown signing type %s, expected one of none, %s", e.Type, strings.Join(types, ", "))
+}
+
+type ErrSigningSetting struct {
+	Type    string
+	Setting string
+}
+
+func (e ErrSigningSetting) Error() string {
+	return fmt.Sprintf("%s signing requires %s to be set", e.Type, e.Setting)
+}
+
+// Signer returns the signer described by the settings, or nil if requests
+// are not signed
+func (s Settings) Signer(replace func(string) string) (Signer, error) {
+	t := s.SigningType.String
+	if t == "" || t == "none" {
+		return nil, nil
+	}
+
+	signer, ok := signers[t]
+	if !ok {
+		return nil, ErrSigningType{Type: t}
+	}
+
+	return signer(s, replace)
+}
+
+// sign the request if a signer is configured
+func (r *Request) sign(req *http.Request) error {
+	if r.signer == nil {
+		return nil
+	}
+
+	return r.signer.Sign(req, time.Now())
+}
+
+// requestBody returns a copy of the request body without consuming it
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, ErrNoReplayBody{Method: req.Method}
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(h, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsSigner implements AWS signature version 4
+type awsSigner struct {
+	keyID        string
+	secret       string
+	sessionToken string
+	region       string
+	service      string
+}
+
+func newAWSSigner(s Settings, replace func(string) string) (Signer, error) {
+	a := &awsSigner{
+		keyID:        replace(s.SigningKeyID.String),
+		secret:       replace(s.SigningSecret.String),
+		sessionToken: replace(s.SigningSessionToken.String),
+		region:       replace(s.SigningRegion.String),
+		service:      replace(s.SigningService.String),
+	}
+
+	switch {
+	case a.keyID == "":
+		return nil, ErrSigningSetting{Type: "aws-sigv4", Setting: "signing-key-id"}
+	case a.secret == "":
+		return nil, ErrSigningSetting{Type: "aws-sigv4", Setting: "signing-secret"}
+	case a.region == "":
+		return nil, ErrSigningSetting{Type: "aws-sigv4", Setting: "signing-region"}
+	case a.service == "":
+		return nil, ErrSigningSetting{Type: "aws-sigv4", Setting: "signing-service"}
+	}
+
+	return a, nil
+}
+
+func (a *awsSigner) Header() string { return "Authorization" }
+
+func (a *awsSigner) Sign(req *http.Request, now time.Time) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.sessionToken)
+	}
+	if a.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	// sign the host, content headers, and all the amz headers
+	headers := map[string]string{"host": host}
+	for key, values := range req.Header {
+		k := strings.ToLower(key)
+		if k == "content-type" || k == "content-md5" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.Join(strings.Fields(strings.Join(values, ",")), " ")
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalPath(req.URL, a.service != "s3"),
+		canonicalQuery(req.URL.Query(), awsEscape),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, a.region, a.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + a.secret)
+	for _, part := range []string{date, a.region, a.service, "aws4_request"} {
+		key = hmacSum(sha256.New, key, part)
+	}
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.keyID, scope, signedHeaders, signature,
+	))
+
+	return nil
+}
+
+// awsEscape percent encodes everything but the RFC 3986 unreserved characters
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsCanonicalPath encodes each path segment, all services other than s3
+// expect the segments to be encoded twice
+func awsCanonicalPath(u *url.URL, twice bool) string {
+	p := u.Path
+	if p == "" {
+		return "/"
+	}
+
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segment = awsEscape(segment)
+		if twice {
+			segment = awsEscape(segment)
+		}
+		segments[i] = segment
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the query by key and then value
+func canonicalQuery(values url.Values, escape func(string) string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(values))
+	for _, k := range keys {
+		v := append([]string(nil), values[k]...)
+		sort.Strings(v)
+		for _, value := range v {
+			parts = append(parts, escape(k)+"="+escape(value))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// hmacSigner signs a newline separated string built from the configured
+// request components, the signature is then sent in a header
+type hmacSigner struct {
+	keyID           string
+	secret          string
+	hash            func() hash.Hash
+	algorithm       string
+	components      []string
+	header          string
+	format          string
+	timestampHeader string
+	base64          bool
+}
+
+func newHMACSigner(s Settings, replace func(string) string) (Signer, error) {
+	h := &hmacSigner{
+		keyID:           replace(s.SigningKeyID.String),
+		secret:          replace(s.SigningSecret.String),
+		algorithm:       s.SigningAlgorithm.String,
+		header:          s.SigningHeader.String,
+		format:          s.SigningFormat.String,
+		timestampHeader: s.SigningTimestampHeader.String,
+	}
+
+	if h.secret == "" {
+		return nil, ErrSigningSetting{Type: "hmac", Setting: "signing-secret"}
+	}
+
+	switch h.algorithm {
+	case "", "sha256":
+		h.algorithm = "sha256"
+		h.hash = sha256.New
+	case "sha1":
+		h.hash = sha1.New
+	case "sha512":
+		h.hash = sha512.New
+	default:
+		return nil, ErrSigningSetting{Type: "hmac", Setting: "signing-algorithm to sha1, sha256, or sha512"}
+	}
+
+	switch s.SigningEncoding.String {
+	case "", "hex":
+	case "base64":
+		h.base64 = true
+	default:
+		return nil, ErrSigningSetting{Type: "hmac", Setting: "signing-encoding to hex or base64"}
+	}
+
+	components := s.SigningComponents.String
+	if components == "" {
+		components = "method,path,timestamp,body-hash"
+	}
+	for _, c := range strings.Split(components, ",") {
+		c = strings.TrimSpace(c)
+		switch {
+		case c == "method", c == "path", c == "query", c == "host", c == "timestamp", c == "body-hash":
+		case strings.HasPrefix(c, "header:"):
+		default:
+			return nil, ErrSigningSetting{Type: "hmac", Setting: fmt.Sprintf("signing-components without unknown component %s", c)}
+		}
+		h.components = append(h.components, c)
+	}
+
+	if h.header == "" {
+		h.header = "Authorization"
+	}
+	if h.format == "" {
+		h.format = "HMAC {key-id}:{signature}"
+	}
+	if h.timestampHeader == "" {
+		h.timestampHeader = "X-Timestamp"
+	}
+
+	return h, nil
+}
+
+func (h *hmacSigner) Header() string { return h.header }
+
+func (h *hmacSigner) Sign(req *http.Request, now time.Time) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(h.timestampHeader, timestamp)
+
+	parts := make([]string, 0, len(h.components))
+	for _, c := range h.components {
+		switch c {
+		case "method":
+			parts = append(parts, strings.ToUpper(req.Method))
+		case "path":
+			parts = append(parts, req.URL.EscapedPath())
+		case "query":
+			parts = append(parts, canonicalQuery(req.URL.Query(), url.QueryEscape))
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			parts = append(parts, host)
+		case "timestamp":
+			parts = append(parts, timestamp)
+		case "body-hash":
+			sum := h.hash()
+			sum.Write(body)
+			parts = append(parts, hex.EncodeToString(sum.Sum(nil)))
+		default:
+			parts = append(parts, req.Header.Get(strings.TrimPrefix(c, "header:")))
+		}
+	}
+
+	mac := hmacSum(h.hash, []byte(h.secret), strings.Join(parts, "\n"))
+	signature := hex.EncodeToString(mac)
+	if h.base64 {
+		signature = base64.StdEncoding.EncodeToString(mac)
+	}
+
+	value := strings.NewReplacer(
+		"{key-id}", h.keyID,
+		"{signature}", signature,
+		"{timestamp}", timestamp,
+		"{algorithm}", h.algorithm,
+	).Replace(h.format)
+	req.Header.Set(h.header, value)
+
+	return nil
+}
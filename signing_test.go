@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAWSSigner(t *testing.T) {
+	// get-vanilla from the AWS signature version 4 test suite
+	s := NewSettings()
+	s.SigningType = sql.NullString{String: "aws-sigv4", Valid: true}
+	s.SigningKeyID = sql.NullString{String: "AKIDEXAMPLE", Valid: true}
+	s.SigningSecret = sql.NullString{String: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Valid: true}
+	s.SigningRegion = sql.NullString{String: "us-east-1", Valid: true}
+	s.SigningService = sql.NullString{String: "service", Valid: true}
+
+	signer, err := s.Signer(replacer(s.Parameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Sign(req, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("expected %s\ngot %s", expected, auth)
+	}
+}
+
+func TestHMACSignerResigns(t *testing.T) {
+	s := NewSettings()
+	s.SigningType = sql.NullString{String: "hmac", Valid: true}
+	s.SigningKeyID = sql.NullString{String: "id", Valid: true}
+	s.SigningSecret = sql.NullString{String: ":secret", Valid: true}
+	s.Parameters["secret"] = "shh"
+
+	signer, err := s.Signer(replacer(s.Parameters))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "https://example.com/things", strings.NewReader(`{"a": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Sign(req, time.Unix(1000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	first := req.Header.Get("Authorization")
+
+	if !strings.HasPrefix(first, "HMAC id:") || req.Header.Get("X-Timestamp") != "1000" {
+		t.Fatalf("unexpected signature headers %v", req.Header)
+	}
+
+	// signing must not consume the body
+	body, err := requestBody(req)
+	if err != nil || string(body) != `{"a": 1}` {
+		t.Fatalf("body consumed by signing %q %v", body, err)
+	}
+
+	if err := signer.Sign(req, time.Unix(2000, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") == first {
+		t.Error("expected signature to change with the timestamp")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTimingFormat is returned for an unknown timing format setting
+type ErrTimingFormat struct {
+	Format string
+}
+
+func (e ErrTimingFormat) Error() string {
+	return fmt.Sprintf("unknown timing format %s, expected text or json", e.Format)
+}
+
+// timingFormat returns the format to show the timing breakdown in, or an
+// empty string when the timing isn't shown.  It is always shown at -vv.
+func (r *Request) timingFormat() (string, error) {
+	if !r.Settings.Timing.Bool && r.verbose < 2 {
+		return "", nil
+	}
+
+	format := r.Settings.TimingFormat
+	if !format.Valid {
+		format = defaultSettings.TimingFormat
+	}
+
+	switch format.String {
+	case "text", "json":
+		return format.String, nil
+	}
+
+	return "", ErrTimingFormat{Format: format.String}
+}
+
+// attemptTiming is how long each phase of a single attempt took.  The
+// server phase is from sending the request to the first byte of the
+// response, and the transfer phase is reading the rest of the body.
+type attemptTiming struct {
+	Attempt   int     `json:"attempt"`
+	Method    string  `json:"method"`
+	URL       string  `json:"url"`
+	Status    int     `json:"status,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Reused    bool    `json:"reused_connection"`
+	RateLimit float64 `json:"rate_limit_ms"`
+	DNS       float64 `json:"dns_ms"`
+	Connect   float64 `json:"connect_ms"`
+	TLS       float64 `json:"tls_ms"`
+	Server    float64 `json:"server_ms"`
+	Transfer  float64 `json:"transfer_ms"`
+	Total     float64 `json:"total_ms"`
+	RetryWait float64 `json:"retry_wait_ms"`
+
+	// the trace callbacks can be called from the dialing goroutines
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	done         bool
+	format       string
+}
+
+func newAttemptTiming(attempt int, req *http.Request, format string) *attemptTiming {
+	return &attemptTiming{
+		Attempt: attempt,
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		format:  format,
+	}
+}
+
+// trace returns a copy of the request that records the timing of each phase
+func (t *attemptTiming) trace(req *http.Request) *http.Request {
+	since := func(start time.Time) float64 {
+		if start.IsZero() {
+			return 0
+		}
+		return ms(time.Since(start))
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.DNS = since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// several addresses can be tried at once, time from the first
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.Connect = since(t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.TLS = since(t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.Reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.wrote = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.firstByte = time.Now()
+			t.Server = ms(t.firstByte.Sub(t.wrote))
+		},
+	}
+
+	t.start = time.Now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// received records the outcome of the attempt.  The transfer is only over
+// once the body has been read, so the timing is shown when it is closed.
+func (t *attemptTiming) received(resp *http.Response, err error) {
+	if err != nil {
+		t.Error = err.Error()
+		return
+	}
+
+	t.Status = resp.StatusCode
+	resp.Body = &timedBody{ReadCloser: resp.Body, timing: t}
+}
+
+// finish the attempt and show its timing when there is a format, only the
+// first call does anything
+func (t *attemptTiming) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+
+	now := time.Now()
+	if !t.firstByte.IsZero() {
+		t.Transfer = ms(now.Sub(t.firstByte))
+	}
+	t.Total = ms(now.Sub(t.start))
+
+	if t.format == "" {
+		return
+	}
+
+	if err := t.Write(log.Writer(), t.format); err != nil {
+		log.Println(err)
+	}
+}
+
+// Write the timing as a line of text or json
+func (t *attemptTiming) Write(w io.Writer, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(t)
+	}
+
+	outcome := fmt.Sprint(t.Status)
+	if t.Error != "" {
+		outcome = "failed: " + t.Error
+	}
+
+	phases := []string{
+		fmt.Sprintf("dns %.2fms", t.DNS),
+		fmt.Sprintf("connect %.2fms", t.Connect),
+		fmt.Sprintf("tls %.2fms", t.TLS),
+		fmt.Sprintf("server %.2fms", t.Server),
+		fmt.Sprintf("transfer %.2fms", t.Transfer),
+		fmt.Sprintf("total %.2fms", t.Total),
+	}
+	if t.Reused {
+		phases = append(phases, "reused connection")
+	}
+	if t.RateLimit > 0 {
+		phases = append(phases, fmt.Sprintf("rate limited %.2fms", t.RateLimit))
+	}
+	if t.RetryWait > 0 {
+		phases = append(phases, fmt.Sprintf("waiting %.2fms to retry", t.RetryWait))
+	}
+
+	_, err := fmt.Fprintf(w, "timing: attempt %d: %s %s %s: %s\n",
+		t.Attempt, t.Method, t.URL, outcome, strings.Join(phases, ", "))
+	return err
+}
+
+// timedBody finishes the attempt timing when the body has been read
+type timedBody struct {
+	io.ReadCloser
+	timing *attemptTiming
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.timing.finish()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.timing.finish()
+	return b.ReadCloser.Close()
+}
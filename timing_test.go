@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTiming(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	r := Request{Settings: NewSettings()}
+	r.Settings.Retries = sql.NullInt64{Int64: 1, Valid: true}
+	r.Settings.RetryDelay = NullDuration{Duration: time.Millisecond, Valid: true}
+	r.Settings.Timing = sql.NullBool{Bool: true, Valid: true}
+	r.Settings.TimingFormat = sql.NullString{String: "json", Valid: true}
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.retry(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	dec := json.NewDecoder(&out)
+	var timings []*attemptTiming
+	for dec.More() {
+		timing := &attemptTiming{}
+		if err := dec.Decode(timing); err != nil {
+			t.Fatal(err)
+		}
+		timings = append(timings, timing)
+	}
+
+	if len(timings) != 2 {
+		t.Fatalf("expected a timing for each attempt got %d", len(timings))
+	}
+
+	first, second := timings[0], timings[1]
+	if first.Attempt != 0 || first.Status != 503 || first.RetryWait <= 0 {
+		t.Errorf("unexpected first attempt %+v", first)
+	}
+	if second.Attempt != 1 || second.Status != 200 || second.RetryWait != 0 {
+		t.Errorf("unexpected second attempt %+v", second)
+	}
+	if second.Server < 10 || second.Total < second.Server {
+		t.Errorf("expected the server phase to include the handler delay %+v", second)
+	}
+
+	var text bytes.Buffer
+	if err := second.Write(&text, "text"); err != nil {
+		t.Fatal(err)
+	}
+	for _, phase := range []string{"dns", "connect", "tls", "server", "transfer", "total"} {
+		if !strings.Contains(text.String(), phase) {
+			t.Errorf("expected %s in %q", phase, text.String())
+		}
+	}
+
+	r.Settings.TimingFormat = sql.NullString{String: "xml", Valid: true}
+	if _, err := r.retry(req); err == nil {
+		t.Error("expected an error for an unknown timing format")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ErrCAFile is returned when the CA bundle contains no usable certificates
+type ErrCAFile struct {
+	File string
+}
+
+func (e ErrCAFile) Error() string {
+	return fmt.Sprintf("no certificates found in ca file %s", e.File)
+}
+
+// clients are shared by requests with the same transport settings, so batch
+// rows, pages, and retries reuse connections instead of leaving them idle
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*http.Client)
+)
+
+// Client returns the http client described by the transport settings.
+// Paths and the proxy go through parameter replacement so they can
+// reference environment variables.
+func (s Settings) Client() (*http.Client, error) {
+	replace := replacer(s.Parameters)
+	key := s.clientKey(replace)
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if client, ok := clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := s.newClient(replace)
+	if err != nil {
+		return nil, err
+	}
+	clients[key] = client
+
+	return client, nil
+}
+
+// clientKey identifies the transport settings after parameter replacement
+func (s Settings) clientKey(replace func(string) string) string {
+	return strings.Join([]string{
+		s.socket(),
+		replace(s.CAFile.String),
+		replace(s.ClientCert.String),
+		replace(s.ClientKey.String),
+		fmt.Sprint(s.Insecure.Bool),
+		fmt.Sprint(s.Proxy.Valid, replace(s.Proxy.String)),
+		fmt.Sprint(s.NoProxy.Valid, s.NoProxy.String),
+		fmt.Sprint(durationOrDefault(s.ConnectTimeout, defaultSettings.ConnectTimeout)),
+		fmt.Sprint(durationOrDefault(s.TLSTimeout, defaultSettings.TLSTimeout)),
+		fmt.Sprint(durationOrDefault(s.Timeout, defaultSettings.Timeout)),
+	}, "\n")
+}
+
+// newClient builds the http client described by the transport settings
+func (s Settings) newClient(replace func(string) string) (*http.Client, error) {
+	tlsConfig, err := s.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := s.proxy(replace)
+	if err != nil {
+		return nil, err
+	}
+
+	dial := s.Dialer().DialContext
+	if socket := s.socket(); socket != "" {
+		// the socket replaces the network connection, so there is nothing to proxy
+		proxy = nil
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return s.Dialer().DialContext(ctx, "unix", socket)
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dial,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: durationOrDefault(s.TLSTimeout, defaultSettings.TLSTimeout),
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   durationOrDefault(s.Timeout, defaultSettings.Timeout),
+	}, nil
+}
+
+// Dialer used to open connections to the service
+func (s Settings) Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   durationOrDefault(s.ConnectTimeout, defaultSettings.ConnectTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// socket returns the unix socket path to connect to, if any
+func (s Settings) socket() string {
+	if !s.Socket.Valid || s.Socket.String == "" {
+		return ""
+	}
+
+	socket, err := homedir.Expand(replacer(s.Parameters)(s.Socket.String))
+	if err != nil {
+		return s.Socket.String
+	}
+
+	return socket
+}
+
+// socketDefaults uses plain http on port 80 when a unix socket is given, as
+// daemons listening on a socket rarely use tls, unless the scheme was given
+// as well.  The port follows the scheme unless it was given.
+func (s *Settings) socketDefaults() {
+	if !s.Socket.Valid || s.Socket.String == "" {
+		return
+	}
+
+	if !s.Scheme.Valid {
+		s.Scheme = sql.NullString{String: "http", Valid: true}
+	}
+
+	if !s.Port.Valid {
+		s.Port = sql.NullInt64{Int64: 80, Valid: true}
+		if s.Scheme.String == "https" {
+			s.Port.Int64 = 443
+		}
+	}
+}
+
+// TLSConfig builds the tls config from the ca, client certificate, and insecure settings
+func (s Settings) TLSConfig() (*tls.Config, error) {
+	replace := replacer(s.Parameters)
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: s.Insecure.Bool,
+	}
+
+	if s.CAFile.Valid && s.CAFile.String != "" {
+		file := replace(s.CAFile.String)
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrCAFile{File: file}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if s.ClientCert.Valid && s.ClientCert.String != "" {
+		key := s.ClientKey.String
+		// the key may be bundled with the certificate
+		if !s.ClientKey.Valid || key == "" {
+			key = s.ClientCert.String
+		}
+
+		cert, err := tls.LoadX509KeyPair(replace(s.ClientCert.String), replace(key))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// proxy returns the proxy selection function, settings override the
+// environment proxy configuration
+func (s Settings) proxy(replace func(string) string) (func(*http.Request) (*url.URL, error), error) {
+	if !s.Proxy.Valid && !s.NoProxy.Valid {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	config := httpproxy.FromEnvironment()
+	if s.Proxy.Valid && s.Proxy.String != "" {
+		p := replace(s.Proxy.String)
+		if _, err := url.Parse(p); err != nil {
+			return nil, err
+		}
+		config.HTTPProxy = p
+		config.HTTPSProxy = p
+	}
+
+	if s.NoProxy.Valid {
+		config.NoProxy = s.NoProxy.String
+	}
+
+	proxyFunc := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+func durationOrDefault(d, deflt NullDuration) time.Duration {
+	if d.Valid {
+		return d.Duration
+	}
+
+	return deflt.Duration
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "daemon.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Host + req.URL.Path))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	s := NewSettings()
+	s.Socket = sql.NullString{String: socket, Valid: true}
+	s.Proxy = sql.NullString{String: "http://proxy.invalid:3128", Valid: true}
+
+	client, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://docker/v1.41/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "docker/v1.41/containers/json" {
+		t.Errorf("unexpected response %s", body)
+	}
+}
+
+// captureStdout returns what was written to stdout while running f
+func captureStdout(t *testing.T, f func()) string {
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = write
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	write.Close()
+
+	out, _ := ioutil.ReadAll(read)
+	return string(out)
+}
+
+func TestUnixSocketDryRun(t *testing.T) {
+	defer testDB(t, "daemon")()
+
+	// as if the service was initialised with only --host and --socket
+	flags := NewSettings()
+	flags.Host = sql.NullString{String: "docker", Valid: true}
+	flags.Socket = sql.NullString{String: "/var/run/docker.sock", Valid: true}
+	flags.socketDefaults()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		s := defaultSettings.Clone()
+		s.Merge(flags)
+		return s.Write(getBucket(tx, "services.daemon"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Request{Service: "daemon", Method: "get", Path: "containers/json", Flags: NewSettings(), DryRun: true}
+	out := captureStdout(t, func() {
+		if _, err := r.Perform(); err != ErrDryRun {
+			t.Errorf("expected a dry run got %v", err)
+		}
+	})
+
+	if r.URL.Scheme != "http" || !strings.Contains(out, "Host: docker:80") {
+		t.Errorf("expected plain http to port 80 got %s\n%s", r.URL.String(), out)
+	}
+
+	// a scheme that was given is kept
+	flags = NewSettings()
+	flags.Scheme = sql.NullString{String: "https", Valid: true}
+	flags.Socket = sql.NullString{String: "/var/run/docker.sock", Valid: true}
+	flags.socketDefaults()
+	if flags.Scheme.String != "https" || flags.Port.Int64 != 443 {
+		t.Errorf("unexpected socket defaults %s %d", flags.Scheme.String, flags.Port.Int64)
+	}
+}
+
+func TestUnixSocketYAML(t *testing.T) {
+	defer testDB(t, "daemon")()
+
+	f, err := ioutil.TempFile("", "rest-yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("host: docker\ntransport:\n  socket: /var/run/docker.sock\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s, err := LoadYAMLSettings(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(db, &Request{Service: "daemon"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Request{Service: "daemon", Method: "get", Path: "containers/json", Flags: NewSettings(), DryRun: true}
+	out := captureStdout(t, func() {
+		if _, err := r.Perform(); err != ErrDryRun {
+			t.Errorf("expected a dry run got %v", err)
+		}
+	})
+
+	if r.URL.Scheme != "http" || !strings.Contains(out, "Host: docker:80") {
+		t.Errorf("expected plain http to port 80 got %s\n%s", r.URL.String(), out)
+	}
+}
+
+// writePEM writes the pem blocks to a temp file and returns its name
+func writePEM(t *testing.T, blocks ...*pem.Block) string {
+	f, err := ioutil.TempFile("", "rest-pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, block := range blocks {
+		if err := pem.Encode(f, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return f.Name()
+}
+
+func TestTLSSettings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	ca := writePEM(t, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	defer os.Remove(ca)
+
+	notCA := writePEM(t, &pem.Block{Type: "NOTHING", Bytes: []byte("useful")})
+	defer os.Remove(notCA)
+
+	tests := []struct {
+		name     string
+		caFile   string
+		insecure bool
+		ok       bool
+	}{
+		{name: "unknown authority"},
+		{name: "ca file", caFile: ca, ok: true},
+		{name: "insecure", insecure: true, ok: true},
+	}
+
+	for _, test := range tests {
+		s := NewSettings()
+		if test.caFile != "" {
+			s.CAFile = sql.NullString{String: test.caFile, Valid: true}
+		}
+		s.Insecure = sql.NullBool{Bool: test.insecure, Valid: true}
+
+		client, err := s.Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != test.ok {
+			t.Errorf("%s: expected success %t got %v", test.name, test.ok, err)
+		}
+	}
+
+	s := NewSettings()
+	s.CAFile = sql.NullString{String: notCA, Valid: true}
+	if _, err := s.Client(); err != (ErrCAFile{File: notCA}) {
+		t.Errorf("expected ca file error got %v", err)
+	}
+}
+
+func TestClientCert(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is bundled with the certificate
+	bundle := writePEM(t, &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	defer os.Remove(bundle)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	s := NewSettings()
+	s.Insecure = sql.NullBool{Bool: true, Valid: true}
+
+	client, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := client.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected the server to require a client certificate")
+	}
+
+	s.ClientCert = sql.NullString{String: bundle, Valid: true}
+	client, err = s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "rest" {
+		t.Errorf("expected the client certificate to be sent got %q", body)
+	}
+}
+
+func TestProxySettings(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("proxied " + req.URL.String()))
+	}))
+	defer proxy.Close()
+
+	s := NewSettings()
+	s.Proxy = sql.NullString{String: proxy.URL, Valid: true}
+
+	client, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://api.example.com/pets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "proxied http://api.example.com/pets" {
+		t.Errorf("expected the request to go through the proxy got %q", body)
+	}
+
+	// hosts in no proxy are connected to directly
+	tests := map[string]bool{
+		"http://api.example.com/pets":   false,
+		"https://api.example.com/pets":  false,
+		"http://auth.example.com/token": true,
+	}
+
+	s.NoProxy = sql.NullString{String: "api.example.com", Valid: true}
+	client, err = s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for target, proxied := range tests {
+		req, err := http.NewRequest("GET", target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := client.Transport.(*http.Transport).Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (u != nil) != proxied {
+			t.Errorf("%s: expected proxied %t got %v", target, proxied, u)
+		}
+		if u != nil && u.String() != proxy.URL {
+			t.Errorf("%s: unexpected proxy %s", target, u)
+		}
+	}
+}
+
+func TestClientReused(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	s := NewSettings()
+	s.Insecure = sql.NullBool{Bool: true, Valid: true}
+
+	client, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// requests with the same settings share the client and its connections
+	again, err := s.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != again {
+		t.Error("expected the client to be reused")
+	}
+
+	s.Timeout = NullDuration{Duration: time.Minute, Valid: true}
+	if other, err := s.Client(); err != nil || other == client {
+		t.Errorf("expected a new client for different settings %v", err)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("expected http/2 got %s", body)
+	}
+}
@@ -0,0 +1,712 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrValidateMode is returned for an unknown openapi validate setting
+type ErrValidateMode struct {
+	Mode string
+}
+
+func (e ErrValidateMode) Error() string {
+	return fmt.Sprintf("unknown openapi validate mode %s, expected off, warn, or error", e.Mode)
+}
+
+// ErrContract is returned when the request or response doesn't match the
+// openapi spec and violations are errors
+type ErrContract struct {
+	What       string
+	Operation  string
+	Violations []string
+}
+
+func (e ErrContract) Error() string {
+	return fmt.Sprintf("%s doesn't match %s in the openapi spec:\n  %s",
+		e.What, e.Operation, strings.Join(e.Violations, "\n  "))
+}
+
+// contract is the operation of the spec that a request was matched to, the
+// response is checked against the same operation
+type contract struct {
+	doc       *openAPIDocument
+	operation map[string]interface{}
+	params    []map[string]interface{}
+	name      string
+	mode      string
+
+	// paginated responses are merged, so the body doesn't match the schema
+	skipBody bool
+}
+
+var (
+	specsMu sync.Mutex
+	specs   = make(map[string]*openAPIDocument)
+)
+
+// loadSpec loads the document once, batches validate every request against it
+func loadSpec(filename string) (*openAPIDocument, error) {
+	specsMu.Lock()
+	defer specsMu.Unlock()
+
+	if doc, ok := specs[filename]; ok {
+		return doc, nil
+	}
+
+	doc, err := loadOpenAPI(filename)
+	if err != nil {
+		return nil, err
+	}
+	specs[filename] = doc
+
+	return doc, nil
+}
+
+// validateRequest finds the operation in the openapi spec for the request
+// and checks the path, query, and json body against it.  It returns nil
+// when validation is off.
+func (r *Request) validateRequest(req *http.Request) (*contract, error) {
+	mode := r.Settings.OpenAPIValidate
+	if !mode.Valid {
+		mode = defaultSettings.OpenAPIValidate
+	}
+
+	switch mode.String {
+	case "off", "":
+		return nil, nil
+	case "warn", "error":
+	default:
+		return nil, ErrValidateMode{Mode: mode.String}
+	}
+
+	if r.Settings.OpenAPISpec.String == "" || r.Method == "ws" {
+		return nil, nil
+	}
+
+	doc, err := loadSpec(r.Settings.OpenAPISpec.String)
+	if err != nil {
+		return nil, err
+	}
+
+	// the spec paths are relative to the base path
+	p := trimBasePath(req.URL.Path, r.Settings.BasePath.String)
+	c, captured := doc.match(req.Method, "/"+p)
+	if c == nil {
+		violation := fmt.Sprintf("no operation for %s %s", req.Method, req.URL.Path)
+		return nil, report("request", "the service", mode.String, []string{violation})
+	}
+	c.mode = mode.String
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, report("request", c.name, c.mode, c.checkRequest(req, captured, body))
+}
+
+// validateResponse checks the status and json body of the response against
+// the operation the request was matched to
+func (c *contract) validateResponse(resp *http.Response, body []byte) error {
+	return report("response", c.name, c.mode, c.checkResponse(resp, body))
+}
+
+// report the violations as warnings, or as an error
+func report(what, operation, mode string, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	if mode == "error" {
+		return ErrContract{What: what, Operation: operation, Violations: violations}
+	}
+
+	for _, violation := range violations {
+		log.Printf("openapi: %s doesn't match %s: %s\n", what, operation, violation)
+	}
+	return nil
+}
+
+// match finds the operation for the method and path, of the templates with
+// the method the one with the most literal segments wins, and returns the values of the path parameters
+func (doc *openAPIDocument) match(method, p string) (*contract, map[string]string) {
+	paths, _ := doc.raw["paths"].(map[string]interface{})
+
+	templates := make([]string, 0, len(paths))
+	for template := range paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	var (
+		best     string
+		captured map[string]string
+		literal  = -1
+	)
+	for _, template := range templates {
+		item, _ := doc.deref(paths[template]).(map[string]interface{})
+		if _, ok := item[strings.ToLower(method)]; !ok {
+			continue
+		}
+
+		values, n, ok := matchTemplate(template, p)
+		if ok && n > literal {
+			best, captured, literal = template, values, n
+		}
+	}
+	if literal < 0 {
+		return nil, nil
+	}
+
+	item, _ := doc.deref(paths[best]).(map[string]interface{})
+	operation, ok := doc.deref(item[strings.ToLower(method)]).(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	c := &contract{
+		doc:       doc,
+		operation: operation,
+		name:      strings.ToUpper(method) + " " + best,
+	}
+	if id, ok := operation["operationId"].(string); ok {
+		c.name = id
+	}
+
+	// operation parameters replace those of the path
+	params := make(map[string]map[string]interface{})
+	for _, list := range []interface{}{item["parameters"], operation["parameters"]} {
+		list, _ := list.([]interface{})
+		for _, param := range list {
+			if param, ok := doc.deref(param).(map[string]interface{}); ok {
+				params[fmt.Sprint(param["in"], " ", param["name"])] = param
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c.params = append(c.params, params[key])
+	}
+
+	return c, captured
+}
+
+// matchTemplate matches the path against a path template, it returns the
+// path parameter values and how many segments matched literally
+func matchTemplate(template, p string) (map[string]string, int, bool) {
+	t := strings.Split(strings.Trim(template, "/"), "/")
+	s := strings.Split(strings.Trim(p, "/"), "/")
+	if len(t) != len(s) {
+		return nil, 0, false
+	}
+
+	values := make(map[string]string)
+	literal := 0
+	for i := range t {
+		if !strings.Contains(t[i], "{") {
+			if t[i] != s[i] {
+				return nil, 0, false
+			}
+			literal++
+			continue
+		}
+
+		// the parameters can be part of a segment, such as {name}.json
+		names := make([]string, 0)
+		pattern := "^"
+		last := 0
+		for _, match := range openAPIPathParam.FindAllStringSubmatchIndex(t[i], -1) {
+			pattern += regexp.QuoteMeta(t[i][last:match[0]]) + "([^/]+?)"
+			names = append(names, t[i][match[2]:match[3]])
+			last = match[1]
+		}
+		pattern += regexp.QuoteMeta(t[i][last:]) + "$"
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, 0, false
+		}
+
+		match := re.FindStringSubmatch(s[i])
+		if match == nil {
+			return nil, 0, false
+		}
+		for j, name := range names {
+			values[name] = match[j+1]
+		}
+	}
+
+	return values, literal, true
+}
+
+func (c *contract) checkRequest(req *http.Request, captured map[string]string, body []byte) []string {
+	violations := make([]string, 0)
+	query := req.URL.Query()
+
+	for _, param := range c.params {
+		name, _ := param["name"].(string)
+		required, _ := param["required"].(bool)
+		schema := c.paramSchema(param)
+
+		switch param["in"] {
+		case "path":
+			if value, ok := captured[name]; ok {
+				violations = c.doc.check(schema, coerce(schema, value), "path parameter "+name, violations)
+			}
+		case "query":
+			values, ok := query[name]
+			if !ok {
+				if required {
+					violations = append(violations, fmt.Sprintf("query parameter %s is required", name))
+				}
+				continue
+			}
+			violations = c.doc.check(schema, coerceAll(c.doc, schema, values), "query parameter "+name, violations)
+		case "body":
+			violations = c.checkBody("body", schema, required, req.Header.Get("Content-Type"), body, violations)
+		}
+	}
+
+	if requestBody, ok := c.doc.deref(c.operation["requestBody"]).(map[string]interface{}); ok {
+		required, _ := requestBody["required"].(bool)
+		schema, declared := c.contentSchema(requestBody, req.Header.Get("Content-Type"))
+		if !declared && len(body) > 0 {
+			violations = append(violations, fmt.Sprintf("content type %s isn't accepted", req.Header.Get("Content-Type")))
+		} else {
+			violations = c.checkBody("body", schema, required, req.Header.Get("Content-Type"), body, violations)
+		}
+	}
+
+	return violations
+}
+
+func (c *contract) checkResponse(resp *http.Response, body []byte) []string {
+	violations := make([]string, 0)
+
+	responses, _ := c.operation["responses"].(map[string]interface{})
+	status := strconv.Itoa(resp.StatusCode)
+	declared, ok := responses[status]
+	if !ok {
+		declared, ok = responses[status[:1]+"XX"]
+	}
+	if !ok {
+		declared, ok = responses["default"]
+	}
+	if !ok {
+		return append(violations, fmt.Sprintf("status %d isn't a declared response", resp.StatusCode))
+	}
+
+	if c.skipBody {
+		return violations
+	}
+
+	response, _ := c.doc.deref(declared).(map[string]interface{})
+	contentType := resp.Header.Get("Content-Type")
+
+	// swagger 2 has a single schema for every content type
+	if schema, ok := response["schema"]; ok {
+		return c.checkBody("body", schema, false, contentType, body, violations)
+	}
+
+	if _, ok := response["content"]; !ok || len(body) == 0 {
+		return violations
+	}
+
+	schema, ok := c.contentSchema(response, contentType)
+	if !ok {
+		return append(violations, fmt.Sprintf("content type %s isn't declared", contentType))
+	}
+
+	return c.checkBody("body", schema, false, contentType, body, violations)
+}
+
+// checkBody validates json bodies against the schema, other content types
+// aren't checked
+func (c *contract) checkBody(name string, schema interface{}, required bool, contentType string, body []byte, violations []string) []string {
+	if len(body) == 0 {
+		if required {
+			violations = append(violations, name+" is required")
+		}
+		return violations
+	}
+
+	if schema == nil || !jsonMediaType(contentType) {
+		return violations
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return append(violations, fmt.Sprintf("%s isn't valid json: %s", name, err))
+	}
+
+	return c.doc.check(schema, v, name, violations)
+}
+
+// contentSchema is the schema of the media type matching the content type
+func (c *contract) contentSchema(body map[string]interface{}, contentType string) (interface{}, bool) {
+	content, _ := body["content"].(map[string]interface{})
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	candidates := []string{mediaType}
+	if mediaType == "" {
+		candidates = append(candidates, "application/json")
+	}
+	if i := strings.Index(mediaType, "/"); i > 0 {
+		candidates = append(candidates, mediaType[:i]+"/*")
+	}
+	candidates = append(candidates, "*/*")
+
+	for _, candidate := range candidates {
+		if media, ok := content[candidate].(map[string]interface{}); ok {
+			return media["schema"], true
+		}
+	}
+
+	return nil, len(content) == 0
+}
+
+// paramSchema is the schema of a parameter, swagger 2 puts it in the parameter
+func (c *contract) paramSchema(param map[string]interface{}) interface{} {
+	if schema, ok := param["schema"]; ok {
+		return c.doc.deref(schema)
+	}
+	return param
+}
+
+func jsonMediaType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// coerce a parameter string to the type the schema expects, values that
+// don't parse are left as strings to be reported
+func coerce(schema interface{}, value string) interface{} {
+	s, _ := schema.(map[string]interface{})
+	switch schemaTypes(s)[0] {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// coerceAll coerces repeated query values, arrays may also be comma separated
+func coerceAll(doc *openAPIDocument, schema interface{}, values []string) interface{} {
+	s, _ := doc.deref(schema).(map[string]interface{})
+	if schemaTypes(s)[0] != "array" {
+		return coerce(s, values[0])
+	}
+
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+
+	items := make([]interface{}, len(values))
+	for i, value := range values {
+		items[i] = coerce(doc.deref(s["items"]), value)
+	}
+	return items
+}
+
+// deref follows local references, such as #/components/schemas/Pet
+func (doc *openAPIDocument) deref(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+
+		var target interface{} = doc.raw
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+			object, _ := target.(map[string]interface{})
+			target = object[key]
+		}
+		v = target
+	}
+
+	return nil
+}
+
+// check the value against the json schema, the common keywords used in
+// openapi documents are supported and formats are ignored
+func (doc *openAPIDocument) check(schema, v interface{}, at string, violations []string) []string {
+	return doc.checkDepth(schema, v, at, violations, 0)
+}
+
+func (doc *openAPIDocument) checkDepth(schema, v interface{}, at string, violations []string, depth int) []string {
+	s, ok := doc.deref(schema).(map[string]interface{})
+	if !ok || depth > 64 {
+		return violations
+	}
+	depth++
+
+	for _, sub := range list(s["allOf"]) {
+		violations = doc.checkDepth(sub, v, at, violations, depth)
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		subs := list(s[keyword])
+		if len(subs) == 0 {
+			continue
+		}
+
+		matched := 0
+		for _, sub := range subs {
+			if len(doc.checkDepth(sub, v, at, nil, depth)) == 0 {
+				matched++
+			}
+		}
+
+		switch {
+		case matched == 0:
+			violations = append(violations, fmt.Sprintf("%s: doesn't match any schema of %s", at, keyword))
+		case keyword == "oneOf" && matched > 1:
+			violations = append(violations, fmt.Sprintf("%s: matches %d schemas of oneOf", at, matched))
+		}
+	}
+
+	if enum := list(s["enum"]); len(enum) > 0 {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found && !(v == nil && nullable(s)) {
+			violations = append(violations, fmt.Sprintf("%s: %s isn't one of the allowed values", at, describe(v)))
+		}
+	}
+
+	types := schemaTypes(s)
+	if types[0] == "" {
+		return doc.checkValue(s, v, at, violations, depth)
+	}
+
+	for _, t := range types {
+		if hasType(t, v) {
+			return doc.checkValue(s, v, at, violations, depth)
+		}
+	}
+	if v == nil && nullable(s) {
+		return violations
+	}
+
+	return append(violations, fmt.Sprintf("%s: expected %s, got %s", at, strings.Join(types, " or "), describe(v)))
+}
+
+// checkValue checks the keywords that apply to the type of the value
+func (doc *openAPIDocument) checkValue(s map[string]interface{}, v interface{}, at string, violations []string, depth int) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		properties, _ := s["properties"].(map[string]interface{})
+		for _, name := range list(s["required"]) {
+			if name, ok := name.(string); ok {
+				if _, ok := v[name]; !ok {
+					violations = append(violations, fmt.Sprintf("%s: missing required field %s", at, name))
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if property, ok := properties[key]; ok {
+				violations = doc.checkDepth(property, v[key], fieldPath(at, key), violations, depth)
+				continue
+			}
+
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					violations = append(violations, fmt.Sprintf("%s: unexpected field %s", at, key))
+				}
+			case map[string]interface{}:
+				violations = doc.checkDepth(additional, v[key], fieldPath(at, key), violations, depth)
+			}
+		}
+
+	case []interface{}:
+		if min, ok := number(s["minItems"]); ok && float64(len(v)) < min {
+			violations = append(violations, fmt.Sprintf("%s: expected at least %v items, got %d", at, min, len(v)))
+		}
+		if max, ok := number(s["maxItems"]); ok && float64(len(v)) > max {
+			violations = append(violations, fmt.Sprintf("%s: expected at most %v items, got %d", at, max, len(v)))
+		}
+		for i, item := range v {
+			violations = doc.checkDepth(s["items"], item, indexPath(at, i), violations, depth)
+		}
+
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := number(s["minLength"]); ok && length < min {
+			violations = append(violations, fmt.Sprintf("%s: expected at least %v characters", at, min))
+		}
+		if max, ok := number(s["maxLength"]); ok && length > max {
+			violations = append(violations, fmt.Sprintf("%s: expected at most %v characters", at, max))
+		}
+		// ecma patterns that go can't compile aren't checked
+		if pattern, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				violations = append(violations, fmt.Sprintf("%s: %q doesn't match %s", at, v, pattern))
+			}
+		}
+
+	case float64:
+		violations = checkRange(s, v, at, violations)
+	}
+
+	return violations
+}
+
+// checkRange checks the minimum and maximum, exclusive bounds are booleans
+// in openapi 3.0 and numbers in 3.1
+func checkRange(s map[string]interface{}, v float64, at string, violations []string) []string {
+	if min, ok := number(s["minimum"]); ok {
+		if exclusive, _ := s["exclusiveMinimum"].(bool); exclusive && v <= min || v < min {
+			violations = append(violations, fmt.Sprintf("%s: %v is less than the minimum %v", at, v, min))
+		}
+	}
+	if min, ok := number(s["exclusiveMinimum"]); ok && v <= min {
+		violations = append(violations, fmt.Sprintf("%s: %v isn't more than %v", at, v, min))
+	}
+
+	if max, ok := number(s["maximum"]); ok {
+		if exclusive, _ := s["exclusiveMaximum"].(bool); exclusive && v >= max || v > max {
+			violations = append(violations, fmt.Sprintf("%s: %v is more than the maximum %v", at, v, max))
+		}
+	}
+	if max, ok := number(s["exclusiveMaximum"]); ok && v >= max {
+		violations = append(violations, fmt.Sprintf("%s: %v isn't less than %v", at, v, max))
+	}
+
+	return violations
+}
+
+// schemaTypes are the types the schema allows, the first is empty when
+// it allows any type
+func schemaTypes(s map[string]interface{}) []string {
+	switch t := s["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, name := range t {
+			types = append(types, fmt.Sprint(name))
+		}
+		if len(types) > 0 {
+			return types
+		}
+	}
+	return []string{""}
+}
+
+func hasType(t string, v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && v == math.Trunc(v)
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+// nullable is the openapi 3.0 and swagger 2 way of allowing null
+func nullable(s map[string]interface{}) bool {
+	n, _ := s["nullable"].(bool)
+	x, _ := s["x-nullable"].(bool)
+	return n || x
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case float64:
+		return fmt.Sprintf("number %v", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func number(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// normalizeYAML converts decoded yaml to the types decoded json has, so
+// schemas can be compared with json values
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeYAML(v[i])
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	}
+	return v
+}
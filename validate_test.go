@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+openapi: 3.1.0
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name]
+      additionalProperties: false
+      properties:
+        id: {type: integer, minimum: 1}
+        name: {type: string, maxLength: 8}
+        tag: {type: [string, "null"], enum: [cat, dog, null]}
+        owner:
+          oneOf:
+            - {type: string, pattern: "^[a-z]+$"}
+            - {type: integer}
+paths:
+  /pets:
+    post:
+      operationId: addPet
+      parameters:
+        - {name: dry, in: query, schema: {type: boolean}}
+        - {name: ids, in: query, schema: {type: array, items: {type: integer}}}
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        "201": {description: created}
+        4XX:
+          description: failed
+          content:
+            application/json:
+              schema: {type: object, required: [message]}
+  /pets/{id}:
+    get:
+      operationId: getPet
+      parameters: [{name: id, in: path, required: true, schema: {type: integer}}]
+      responses:
+        "200":
+          description: found
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+  /pets/mine:
+    put:
+      operationId: putMine
+  /files/{name}.{ext}:
+    get:
+      operationId: getFile
+`
+
+func loadTestSpec(t *testing.T) *openAPIDocument {
+	f, err := ioutil.TempFile("", "spec*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(testSpec); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	doc, err := loadOpenAPI(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestOpenAPIMatch(t *testing.T) {
+	doc := loadTestSpec(t)
+
+	tests := []struct {
+		method, path, operation string
+		values                  map[string]string
+	}{
+		{"GET", "/pets/12", "getPet", map[string]string{"id": "12"}},
+		{"PUT", "/pets/mine", "putMine", map[string]string{}},
+		{"GET", "/pets/mine", "getPet", map[string]string{"id": "mine"}},
+		{"GET", "/files/report.tar.gz", "getFile", map[string]string{"name": "report", "ext": "tar.gz"}},
+		{"DELETE", "/pets/12", "", nil},
+		{"GET", "/pets/12/toys", "", nil},
+	}
+
+	for _, test := range tests {
+		c, values := doc.match(test.method, test.path)
+		switch {
+		case test.operation == "" && c != nil:
+			t.Errorf("%s %s: expected no match got %s", test.method, test.path, c.name)
+		case test.operation == "":
+		case c == nil:
+			t.Errorf("%s %s: expected %s got no match", test.method, test.path, test.operation)
+		case c.name != test.operation:
+			t.Errorf("%s %s: expected %s got %s", test.method, test.path, test.operation, c.name)
+		}
+
+		for key, value := range test.values {
+			if values[key] != value {
+				t.Errorf("%s %s: expected %s=%s got %v", test.method, test.path, key, value, values)
+			}
+		}
+	}
+}
+
+func TestOpenAPIValidate(t *testing.T) {
+	doc := loadTestSpec(t)
+
+	check := func(method, url, body string) []string {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		c, captured := doc.match(method, req.URL.Path)
+		if c == nil {
+			t.Fatalf("no operation for %s %s", method, url)
+		}
+		return c.checkRequest(req, captured, []byte(body))
+	}
+
+	violations := check("POST", "http://example.com/pets?dry=true&ids=1,2", `{"id": 1, "name": "rex", "tag": null, "owner": "ann"}`)
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, got %q", violations)
+	}
+
+	violations = check("POST", "http://example.com/pets?dry=maybe&ids=1,x", `{"id": 0, "name": "much too long", "tag": "fish", "owner": 1.5, "age": 3}`)
+	expected := []string{
+		`query parameter dry: expected boolean, got string "maybe"`,
+		`query parameter ids[1]: expected integer, got string "x"`,
+		`body: unexpected field age`,
+		`body.id: 0 is less than the minimum 1`,
+		`body.name: expected at most 8 characters`,
+		`body.owner: doesn't match any schema of oneOf`,
+		`body.tag: string "fish" isn't one of the allowed values`,
+	}
+	if strings.Join(violations, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected violations\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(violations, "\n"))
+	}
+
+	violations = check("POST", "http://example.com/pets", "")
+	if len(violations) != 1 || violations[0] != "body is required" {
+		t.Errorf("expected the body to be required, got %q", violations)
+	}
+
+	violations = check("GET", "http://example.com/pets/abc", "")
+	if len(violations) != 1 || violations[0] != `path parameter id: expected integer, got string "abc"` {
+		t.Errorf("unexpected path violations %q", violations)
+	}
+
+	c, _ := doc.match("POST", "/pets")
+	response := func(status int, body string) []string {
+		resp := &http.Response{StatusCode: status, Header: http.Header{"Content-Type": {"application/json"}}}
+		return c.checkResponse(resp, []byte(body))
+	}
+
+	if violations := response(201, ""); len(violations) != 0 {
+		t.Errorf("expected no violations, got %q", violations)
+	}
+	if violations := response(404, `{"message": "not found"}`); len(violations) != 0 {
+		t.Errorf("expected 4XX to match, got %q", violations)
+	}
+	if violations := response(409, `{}`); len(violations) != 1 || violations[0] != "body: missing required field message" {
+		t.Errorf("unexpected response violations %q", violations)
+	}
+	if violations := response(500, `{}`); len(violations) != 1 || violations[0] != "status 500 isn't a declared response" {
+		t.Errorf("unexpected status violations %q", violations)
+	}
+}
+
+func TestTrimBasePath(t *testing.T) {
+	tests := []struct {
+		path, base, expected string
+	}{
+		{"/v1/users", "/v1", "users"},
+		{"/v1", "v1/", ""},
+		{"/v10/users", "/v1", "v10/users"},
+		{"/users", "", "users"},
+	}
+
+	for _, test := range tests {
+		if p := trimBasePath(test.path, test.base); p != test.expected {
+			t.Errorf("%s %s: expected %q got %q", test.path, test.base, test.expected, p)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/websocket"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	wsInput string
+	wsWait  time.Duration
+)
+
+// the largest message that can be read from the input
+const maxMessageSize = 1 << 20
+
+// wsFlags apply to websocket sessions and websocket aliases
+func wsFlags(cmd *kingpin.CmdClause) {
+	cmd.Flag("input", "file to read messages from, one message per line, - reads from stdin").
+		Default("-").
+		StringVar(&wsInput)
+	cmd.Flag("wait", "how long to wait for frames after the input ends, by default waits for the server to close the connection").
+		DurationVar(&wsWait)
+}
+
+// Session opens a websocket session, displays the frames, and exits
+func Session(r *Request) {
+	if err := r.LoadData(); err != nil {
+		log.Println("error loading request data:", err)
+		os.Exit(1)
+	}
+
+	in := os.Stdin
+	if wsInput != "-" {
+		var err error
+		in, err = os.Open(wsInput)
+		if err != nil {
+			log.Println("error opening input:", err)
+			os.Exit(1)
+		}
+		defer in.Close()
+	}
+
+	response := Response{verbose: r.verbose}
+	if err := r.WebSocket(in, &response); err != nil {
+		log.Println("websocket error:", err)
+		os.Exit(1)
+	}
+}
+
+// WebSocket connects to the path using the same headers, queries, auth, and
+// parameters as a normal request.  The request data is sent as the first
+// message, followed by each line of input.  Every received frame goes
+// through the response hook and filter before being displayed.
+func (r *Request) WebSocket(in io.Reader, out *Response) error {
+	if err := db.Update(r.LoadSettings); err != nil {
+		return err
+	}
+
+	if r.lua == nil {
+		r.lua = &luaEnv{}
+		defer r.lua.Close()
+	}
+	out.lua = r.lua
+
+	r.Method = "GET"
+	req, err := r.Prepare()
+	if err != nil {
+		return err
+	}
+
+	if err := r.sign(req); err != nil {
+		return err
+	}
+
+	if r.DryRun {
+		dump, err := httputil.DumpRequestOut(req, false)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(dump))
+		return nil
+	}
+
+	u := *req.URL
+	origin := url.URL{Scheme: u.Scheme, Host: u.Host}
+	u.Scheme = "ws"
+	if req.URL.Scheme == "https" {
+		u.Scheme = "wss"
+	}
+
+	config, err := websocket.NewConfig(u.String(), origin.String())
+	if err != nil {
+		return err
+	}
+	config.Header = req.Header
+	config.Dialer = r.Settings.Dialer()
+	config.TlsConfig, err = r.Settings.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if r.verbose > 0 {
+		log.Println("connecting to", u.String())
+	}
+
+	conn, err := r.dialWebSocket(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	out.apply(r.Settings)
+	out.service = r.Service
+	out.resp = &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: http.Header{}}
+	out.streamed = true
+
+	received := make(chan error, 1)
+	go func() {
+		for {
+			var frame []byte
+			if err := websocket.Message.Receive(conn, &frame); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				received <- err
+				return
+			}
+
+			if err := out.displayEvent(frame); err != nil {
+				received <- err
+				return
+			}
+		}
+	}()
+
+	sent := make(chan error, 1)
+	go func() {
+		// the data has already been through parameter replacement
+		if r.Data != "" {
+			if err := websocket.Message.Send(conn, r.Data); err != nil {
+				sent <- err
+				return
+			}
+		}
+
+		replace := replacer(r.Settings.Parameters)
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			if scanner.Text() == "" {
+				continue
+			}
+
+			if err := websocket.Message.Send(conn, replace(scanner.Text())); err != nil {
+				sent <- err
+				return
+			}
+		}
+		sent <- scanner.Err()
+	}()
+
+	select {
+	case err := <-received:
+		return err
+	case err := <-sent:
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.verbose > 0 {
+		log.Println("input finished, waiting for frames")
+	}
+
+	if wsWait == 0 {
+		return <-received
+	}
+
+	select {
+	case err := <-received:
+		return err
+	case <-time.After(wsWait):
+		return nil
+	}
+}
+
+// dialWebSocket connects over the unix socket when one is set
+func (r *Request) dialWebSocket(config *websocket.Config) (*websocket.Conn, error) {
+	socket := r.Settings.socket()
+	if socket == "" {
+		return websocket.DialConfig(config)
+	}
+
+	c, err := r.Settings.Dialer().Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	// the socket only replaces the network connection, wss still uses tls
+	if config.Location.Scheme == "wss" {
+		tlsConfig := config.TlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = config.Location.Hostname()
+		}
+
+		tlsConn := tls.Client(c, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tlsConn
+	}
+
+	conn, err := websocket.NewClient(config, c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocket(t *testing.T) {
+	defer testDB(t, "ws")()
+
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		req := conn.Request()
+		if req.URL.Path != "/feed/prices" || req.URL.Query().Get("v") != "2" {
+			t.Errorf("unexpected websocket url %s", req.URL)
+		}
+		if req.Header.Get("X-Token") != "secret" {
+			t.Errorf("expected stored header to be sent, got %q", req.Header.Get("X-Token"))
+		}
+
+		for {
+			var msg string
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				return
+			}
+			websocket.Message.Send(conn, `{"echo": "`+msg+`"}`)
+			if msg == "bye" {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	host, port, _ := strings.Cut(u.Host, ":")
+	p, _ := strconv.Atoi(port)
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		s := NewSettings()
+		s.Scheme = sql.NullString{String: "http", Valid: true}
+		s.Host = sql.NullString{String: host, Valid: true}
+		s.Port = sql.NullInt64{Int64: int64(p), Valid: true}
+		s.Headers["X-Token"] = "secret"
+		s.Queries["v"] = "2"
+		s.Parameters["feed"] = "prices"
+		s.Parameters["name"] = "bye"
+		s.Filter = sql.NullString{String: "echo", Valid: true}
+		return s.Write(getBucket(tx, "services.ws"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	resp := Response{out: &out}
+	r := Request{
+		Service: "ws",
+		Path:    "feed/:feed",
+		Data:    "subscribe",
+	}
+	wsWait = time.Second
+
+	if err := r.WebSocket(strings.NewReader("hello\n\n:name\n"), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != `"subscribe"`+"\n"+`"hello"`+"\n"+`"bye"`+"\n" {
+		t.Errorf("unexpected frames displayed %q", out.String())
+	}
+}
+
+func TestWebSocketTLSOverSocket(t *testing.T) {
+	defer testDB(t, "wss")()
+
+	dir, err := ioutil.TempDir("", "rest-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "daemon.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(websocket.Handler(func(conn *websocket.Conn) {
+		if conn.Request().TLS == nil {
+			t.Error("expected the websocket to use tls")
+		}
+		websocket.Message.Send(conn, `"secure"`)
+	}))
+	server.Listener = listener
+	server.StartTLS()
+	defer server.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		s := NewSettings()
+		s.Scheme = sql.NullString{String: "https", Valid: true}
+		s.Host = sql.NullString{String: "daemon", Valid: true}
+		s.Port = sql.NullInt64{Int64: 443, Valid: true}
+		s.Socket = sql.NullString{String: socket, Valid: true}
+		s.Insecure = sql.NullBool{Bool: true, Valid: true}
+		return s.Write(getBucket(tx, "services.wss"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	resp := Response{out: &out}
+	r := Request{Service: "wss", Path: "events"}
+	wsWait = time.Second
+
+	if err := r.WebSocket(strings.NewReader(""), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != `"secure"`+"\n" {
+		t.Errorf("unexpected frames displayed %q", out.String())
+	}
+}